@@ -26,6 +26,11 @@
             pkgs.go
             pkgs.golangci-lint
             pkgs.gopls
+          ] ++ pkgs.lib.optionals pkgs.stdenv.isLinux [
+            # Network namespace tests, see README
+            pkgs.coreutils
+            pkgs.iptables # provides both *-legacy and *-nft variants
+            pkgs.util-linux # nsenter
           ];
         };
       });
@@ -0,0 +1,212 @@
+//go:build ignore
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	nameNormalizer  = regexp.MustCompile(`[^a-z0-9]+`)
+	nameAnnotations = regexp.MustCompile(`\s*\(.*\)`)
+	unnamedPrefixes = []string{"unassigned", "reserved", "private experimentation", "rfc3692-style", "icmp messages utilized"}
+)
+
+type icmpRegistry struct {
+	paramsFile string
+	prefix     string
+	typesVar   string
+	namesVar   string
+}
+
+func main() {
+	if err := entrypoint(); err != nil {
+		fmt.Fprintf(os.Stderr, "unhandled error: %s\n", err)
+	}
+}
+
+func entrypoint() (err error) {
+	var wd string
+	var projectRoot string
+
+	if wd, err = os.Getwd(); err != nil {
+		return
+	}
+
+	if projectRoot, err = resolveProjectRoot(wd); err != nil {
+		return
+	}
+
+	targetFileName := filepath.Join(projectRoot, "internal/rule/flags_icmp_types.go")
+	registries := []icmpRegistry{
+		{
+			paramsFile: filepath.Join(projectRoot, "hack/icmp-parameters-types.csv"),
+			prefix:     "icmpTypePrefix",
+			typesVar:   "validICMPTypes",
+			namesVar:   "icmpTypeNames",
+		},
+		{
+			paramsFile: filepath.Join(projectRoot, "hack/icmpv6-parameters-2.csv"),
+			prefix:     "icmpv6TypePrefix",
+			typesVar:   "validICMPV6Types",
+			namesVar:   "icmpv6TypeNames",
+		},
+	}
+
+	var buf strings.Builder
+	buf.WriteString("package rule\n")
+	buf.WriteString("\n")
+	buf.WriteString("//go:generate go run ../../hack/parse_icmp_params.go\n")
+	buf.WriteString("\n")
+	buf.WriteString("var icmpTypePrefix = \"icmp:\"\n")
+	buf.WriteString("var icmpv6TypePrefix = \"icmpv6:\"\n")
+
+	for _, registry := range registries {
+		if err = writeRegistry(&buf, registry); err != nil {
+			return
+		}
+	}
+
+	var fileBuf []byte
+	if fileBuf, err = format.Source([]byte(buf.String())); err != nil {
+		err = fmt.Errorf("failed to format generated code: %w", err)
+		return
+	}
+
+	err = ioutil.WriteFile(targetFileName, fileBuf, 0644)
+
+	return
+}
+
+func writeRegistry(buf *strings.Builder, registry icmpRegistry) (err error) {
+	var paramsFile *os.File
+	if paramsFile, err = os.OpenFile(registry.paramsFile, os.O_RDONLY, 0); err != nil {
+		err = fmt.Errorf("unable to open csv file: %w", err)
+		return
+	}
+	defer func() { _ = paramsFile.Close() }()
+
+	csvReader := csv.NewReader(paramsFile)
+
+	var readErr error
+	var records []string
+
+	var types strings.Builder
+	var names strings.Builder
+	seenNames := map[string]bool{}
+	headersSeen := false
+
+	for records, readErr = csvReader.Read(); readErr != io.EOF; records, readErr = csvReader.Read() {
+		if !headersSeen {
+			headersSeen = true
+			continue
+		}
+		if readErr != nil {
+			err = fmt.Errorf("failed to process csv file: %w", readErr)
+			return
+		}
+
+		var kinds []int
+		if kinds, err = parseTypes(records[0]); err != nil {
+			return
+		}
+
+		meaning := records[1]
+		for _, kind := range kinds {
+			types.WriteString(fmt.Sprintf("\t(%s+\"%d\"): true, // %s\n", registry.prefix, kind, meaning))
+		}
+
+		name := normalizeName(meaning)
+		if name == "" || len(kinds) != 1 {
+			continue
+		}
+		if seenNames[name] {
+			err = fmt.Errorf("duplicate icmp type name '%s' in %s", name, registry.paramsFile)
+			return
+		}
+		seenNames[name] = true
+		names.WriteString(fmt.Sprintf("\t(%s+\"%s\"): \"%d\",\n", registry.prefix, name, kinds[0]))
+	}
+
+	buf.WriteString("\n")
+	buf.WriteString(fmt.Sprintf("var %s = map[string]bool{\n", registry.typesVar))
+	buf.WriteString(types.String())
+	buf.WriteString("}\n")
+	buf.WriteString("\n")
+	buf.WriteString(fmt.Sprintf("var %s = map[string]string{\n", registry.namesVar))
+	buf.WriteString(names.String())
+	buf.WriteString("}\n")
+	return
+}
+
+func parseTypes(typesRaw string) (types []int, err error) {
+	if strings.Contains(typesRaw, "-") {
+		var begin int
+		var end int
+		split := strings.SplitN(typesRaw, "-", 2)
+
+		if begin, err = strconv.Atoi(split[0]); err != nil {
+			err = fmt.Errorf("unable to parse type range beginning: %w", err)
+			return
+		}
+
+		if end, err = strconv.Atoi(split[1]); err != nil {
+			err = fmt.Errorf("unable to parse type range end: %w", err)
+			return
+		}
+
+		for i := begin; i <= end; i++ {
+			types = append(types, i)
+		}
+	} else {
+		var kind int
+		if kind, err = strconv.Atoi(typesRaw); err != nil {
+			err = fmt.Errorf("unable to parse type: %w", err)
+			return
+		}
+
+		types = append(types, kind)
+	}
+	return
+}
+
+// normalizeName turns IANA type name into lowercase dash separated form, e.g. 'Echo Reply' -> 'echo-reply'.
+// Returns empty string for entries which should not be addressable by name
+func normalizeName(meaning string) string {
+	name := strings.ToLower(meaning)
+	for _, prefix := range unnamedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return ""
+		}
+	}
+
+	name = nameAnnotations.ReplaceAllString(name, "")
+	return strings.Trim(nameNormalizer.ReplaceAllString(name, "-"), "-")
+}
+
+func resolveProjectRoot(path string) (projectRoot string, err error) {
+	projectRoot = path
+	for {
+		if _, err = os.Stat(filepath.Join(projectRoot, "go.mod")); err == nil {
+			return
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return
+		}
+
+		projectRoot = filepath.Dir(projectRoot)
+		if projectRoot == "/" {
+			err = fmt.Errorf("failed to resolve project root")
+			return
+		}
+	}
+}
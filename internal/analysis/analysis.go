@@ -0,0 +1,168 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+type Kind int
+
+const (
+	// Rule can never match, because an earlier rule with a different action matches all its packets
+	KindShadowed Kind = iota
+	// Rule never changes the outcome, because an earlier rule with the same action matches all its packets
+	KindRedundant
+	// Rule partially overlaps an earlier rule with a different action, outcome depends on the order
+	KindConflict
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindShadowed:
+		return "shadowed"
+	case KindRedundant:
+		return "redundant"
+	case KindConflict:
+		return "conflict"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Finding describes a problem with a rule. Indexes refer to the analyzed rule slice
+type Finding struct {
+	Kind   Kind
+	Index  int
+	Other  int
+	Reason string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("rule %d: %s by rule %d: %s", f.Index, f.Kind, f.Other, f.Reason)
+}
+
+// Analyze checks rules in installation order (see rule.PriorityOrder) and reports shadowed, redundant and conflicting rules.
+// Rules are compared in their normalized form, invalid rules are skipped.
+func Analyze(rules []rule.Rule) (findings []Finding) {
+	order := rule.PriorityOrder(rules)
+	sorted := rule.SortByPriority(rules)
+
+	valid := make([]bool, len(sorted))
+	for i := range sorted {
+		valid[i] = sorted[i].Validate() == nil
+	}
+
+	for i := range sorted {
+		if !valid[i] {
+			continue
+		}
+
+		for j := 0; j < i; j++ {
+			if !valid[j] {
+				continue
+			}
+
+			finding, found := compare(&sorted[j], &sorted[i])
+			if !found {
+				continue
+			}
+
+			finding.Index = order[i]
+			finding.Other = order[j]
+			findings = append(findings, finding)
+
+			// Fully covered rules are not compared any further
+			if finding.Kind != KindConflict {
+				break
+			}
+		}
+	}
+	return
+}
+
+// compare checks later rule against an earlier one
+func compare(earlier, later *rule.Rule) (finding Finding, found bool) {
+	sameAction := earlier.Action == later.Action
+
+	if earlier.Covers(later) {
+		found = true
+		if !sameAction {
+			finding.Kind = KindShadowed
+			finding.Reason = fmt.Sprintf("all matching packets are already handled with action '%s'", earlier.Action)
+		} else if later.Covers(earlier) {
+			finding.Kind = KindRedundant
+			finding.Reason = "duplicate of an earlier rule"
+		} else {
+			finding.Kind = KindRedundant
+			finding.Reason = fmt.Sprintf("all matching packets are already handled by a broader rule with the same action '%s'", earlier.Action)
+		}
+		return
+	}
+
+	if !sameAction && earlier.Overlaps(later) {
+		found = true
+		finding.Kind = KindConflict
+		finding.Reason = fmt.Sprintf("overlapping packets get action '%s' instead of '%s'", earlier.Action, later.Action)
+	}
+	return
+}
+
+// LintError is returned by Lint when rules contain findings of failing kinds
+type LintError struct {
+	Findings []Finding
+}
+
+func (e *LintError) Error() string {
+	var lines []string
+	for _, finding := range e.Findings {
+		lines = append(lines, finding.String())
+	}
+	return fmt.Sprintf("rules failed lint: %s", strings.Join(lines, "; "))
+}
+
+// Lint returns a check failing when rules contain findings of given kinds (shadowed rules when none given).
+// Suitable to be used with chain.WithPreflight.
+func Lint(failOn ...Kind) func([]rule.Rule) error {
+	return func(rules []rule.Rule) (err error) {
+		if failing := findingsOf(rules, failOn); len(failing) > 0 {
+			err = &LintError{Findings: failing}
+		}
+		return
+	}
+}
+
+// Warn returns a check logging findings of given kinds (shadowed rules when none given) as warnings, it never fails.
+// Suitable to be used with chain.WithPreflight.
+func Warn(kinds ...Kind) func([]rule.Rule) error {
+	return func(rules []rule.Rule) (err error) {
+		for _, finding := range findingsOf(rules, kinds) {
+			zap.L().Warn("rule analysis finding",
+				zap.Stringer("kind", finding.Kind),
+				zap.Int("rule", finding.Index),
+				zap.Int("other", finding.Other),
+				zap.String("reason", finding.Reason),
+			)
+		}
+		return
+	}
+}
+
+func findingsOf(rules []rule.Rule, kinds []Kind) (findings []Finding) {
+	if len(kinds) == 0 {
+		kinds = []Kind{KindShadowed}
+	}
+
+	for _, finding := range Analyze(rules) {
+		for _, kind := range kinds {
+			if finding.Kind == kind {
+				findings = append(findings, finding)
+				break
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,221 @@
+package analysis_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/ZentriaMC/swdfw/internal/analysis"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    []rule.Rule
+		findings []analysis.Finding
+	}{
+		{
+			name: "block all above narrower allow",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+			},
+			findings: []analysis.Finding{{Kind: analysis.KindShadowed, Index: 1, Other: 0}},
+		},
+		{
+			name: "duplicate rules",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+				{Protocol: "udp", StartPort: 53, Action: "allow"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+			},
+			findings: []analysis.Finding{{Kind: analysis.KindRedundant, Index: 2, Other: 0}},
+		},
+		{
+			name: "broader rule with same action",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), Action: "allow"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.1.0.0/16"), StartPort: 443, Action: "allow"},
+			},
+			findings: []analysis.Finding{{Kind: analysis.KindRedundant, Index: 1, Other: 0}},
+		},
+		{
+			name: "partial overlap with different action",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 1000, EndPort: 2000, Action: "block"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.1.0.0/16"), StartPort: 1500, EndPort: 2500, Action: "allow"},
+			},
+			findings: []analysis.Finding{{Kind: analysis.KindConflict, Index: 1, Other: 0}},
+		},
+		{
+			name: "disjoint rules",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), Action: "block"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("192.168.0.0/16"), Action: "allow"},
+				{Protocol: "udp", Source: rule.MustParseCIDR("10.0.0.0/8"), Action: "allow"},
+				{Protocol: "icmp", Flags: []string{"icmp:echo-request"}, Action: "allow"},
+				{Protocol: "icmp", Flags: []string{"icmp:echo-reply"}, Action: "block"},
+			},
+		},
+		{
+			name: "actions are compared normalized",
+			rules: []rule.Rule{
+				{Protocol: "tcp", StartPort: 22, Action: "Allow"},
+				{Protocol: "TCP", StartPort: 22, Action: "allow"},
+			},
+			findings: []analysis.Finding{{Kind: analysis.KindRedundant, Index: 1, Other: 0}},
+		},
+		{
+			name: "priority changes installation order",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+				{Protocol: "tcp", Action: "block", Priority: -1},
+			},
+			findings: []analysis.Finding{{Kind: analysis.KindShadowed, Index: 0, Other: 1}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			findings := analysis.Analyze(c.rules)
+			for i := range findings {
+				if findings[i].Reason == "" {
+					t.Errorf("finding %s has no reason", findings[i])
+				}
+				findings[i].Reason = ""
+			}
+
+			if !reflect.DeepEqual(findings, c.findings) {
+				t.Errorf("unexpected findings %+v, expected %+v", findings, c.findings)
+			}
+		})
+	}
+}
+
+func TestAnalyzeShadowed(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    []rule.Rule
+		shadowed []analysis.Finding
+	}{
+		{
+			name: "block all above narrower allow",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+			},
+			shadowed: []analysis.Finding{{Kind: analysis.KindShadowed, Index: 1, Other: 0}},
+		},
+		{
+			name: "narrower block above broader allow",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "block"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "allow"},
+			},
+		},
+		{
+			name: "all protocols and port range",
+			rules: []rule.Rule{
+				{Protocol: "all", Action: "allow"},
+				{Protocol: "udp", Source: rule.MustParseCIDR("2001:db8::/32"), StartPort: 53, Action: "block"},
+				{Protocol: "tcp", StartPort: 1000, EndPort: 2000, Action: "allow"},
+			},
+			shadowed: []analysis.Finding{{Kind: analysis.KindShadowed, Index: 1, Other: 0}},
+		},
+		{
+			name: "ipv4 only rule does not cover dual-stack rule",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"},
+				{Protocol: "tcp", Action: "allow"},
+			},
+		},
+		{
+			name: "rate limited rule does not cover",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Action: "allow", RateLimit: &rule.RateLimit{Rate: "10/minute"}},
+				{Protocol: "tcp", Action: "block"},
+			},
+		},
+		{
+			name: "state subset",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Action: "allow", Flags: []string{"state:established", "state:related"}},
+				{Protocol: "tcp", Action: "block", Flags: []string{"state:established"}},
+				{Protocol: "tcp", Action: "block", Flags: []string{"state:new"}},
+			},
+			shadowed: []analysis.Finding{{Kind: analysis.KindShadowed, Index: 1, Other: 0}},
+		},
+		{
+			name: "negated source",
+			rules: []rule.Rule{
+				{Protocol: "tcp", Source: rule.MustParseCIDR("!10.0.0.0/8"), Action: "block"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("192.0.2.0/24"), Action: "allow"},
+				{Protocol: "tcp", Source: rule.MustParseCIDR("10.1.0.0/16"), Action: "allow"},
+			},
+			shadowed: []analysis.Finding{{Kind: analysis.KindShadowed, Index: 1, Other: 0}},
+		},
+	}
+
+	for _, c := range cases {
+		var shadowed []analysis.Finding
+		for _, finding := range analysis.Analyze(c.rules) {
+			if finding.Kind == analysis.KindShadowed {
+				finding.Reason = ""
+				shadowed = append(shadowed, finding)
+			}
+		}
+
+		if !reflect.DeepEqual(shadowed, c.shadowed) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.shadowed, shadowed)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	rules := []rule.Rule{
+		{Protocol: "tcp", StartPort: 22, Action: "allow"},
+		{Protocol: "tcp", StartPort: 22, Action: "allow"},
+	}
+
+	if err := analysis.Lint()(rules); err != nil {
+		t.Errorf("redundant rules should not fail default lint: %s", err)
+	}
+
+	err := analysis.Lint(analysis.KindShadowed, analysis.KindRedundant)(rules)
+	var lintErr *analysis.LintError
+	if !errors.As(err, &lintErr) || len(lintErr.Findings) != 1 {
+		t.Errorf("expected lint error with one finding, got %v", err)
+	}
+}
+
+func TestWarn(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	rules := []rule.Rule{
+		{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+		{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+		{Protocol: "udp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"},
+		{Protocol: "udp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 53, Action: "allow"},
+	}
+
+	if err := analysis.Warn()(rules); err != nil {
+		t.Errorf("warn should never fail: %s", err)
+	}
+	if logs.Len() != 1 {
+		t.Errorf("expected a warning for the shadowed rule, got %v", logs.All())
+	}
+
+	logs.TakeAll()
+	if err := analysis.Warn(analysis.KindRedundant)(rules); err != nil {
+		t.Errorf("warn should never fail: %s", err)
+	}
+	if logs.Len() != 1 {
+		t.Errorf("expected a warning for the redundant rule, got %v", logs.All())
+	}
+}
@@ -0,0 +1,53 @@
+package chain
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// Change was cancelled before new rules took effect, old rules are still in place
+	ErrCancelledBeforeSwap = errors.New("cancelled before swapping chains")
+	// Change was cancelled after new rules took effect and cleaning up old rules failed
+	ErrCancelledAfterSwap = errors.New("cancelled after swapping chains")
+)
+
+// CancelledError is returned when ConfigureChain or InstallBaseChain fails while its context is done.
+// It matches ErrCancelledBeforeSwap or ErrCancelledAfterSwap and the context error using errors.Is
+type CancelledError struct {
+	Swapped bool
+	Err     error
+	// Context error, Err may be an error of the step that failed meanwhile
+	ctxErr error
+}
+
+func (e *CancelledError) Error() string {
+	return e.phase().Error() + ": " + e.Err.Error()
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CancelledError) Is(target error) bool {
+	return target == e.phase() || (e.ctxErr != nil && target == e.ctxErr)
+}
+
+func (e *CancelledError) phase() error {
+	if e.Swapped {
+		return ErrCancelledAfterSwap
+	}
+	return ErrCancelledBeforeSwap
+}
+
+// cancelled wraps err into CancelledError when ctx is done, nil err is replaced by ctx error
+func cancelled(ctx context.Context, swapped bool, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return &CancelledError{Swapped: swapped, Err: err, ctxErr: ctx.Err()}
+}
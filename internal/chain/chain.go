@@ -3,6 +3,7 @@ package chain
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/ZentriaMC/swdfw/internal/cmdchain"
 	"github.com/ZentriaMC/swdfw/internal/rule"
@@ -10,13 +11,61 @@ import (
 
 type ChainManager interface {
 	io.Closer
-	ConfigureChain(ctx context.Context, name, parentChain, jumpTo string, rules []rule.Rule) (err error)
+	ConfigureChain(ctx context.Context, name, parentChain, jumpTo string, rules []rule.Rule, opts ...ChainOpt) (err error)
 	InstallBaseChain(ctx context.Context, name, parentChain string) (err error)
 	DeleteChain(ctx context.Context, name string) (err error)
 }
 
 type ChainManagerOpt func(ChainManager)
 
+// DefaultLockRetryPolicy retries commands failing due to xtables lock contention for a few seconds
+var DefaultLockRetryPolicy = cmdchain.RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Jitter:         0.5,
+	Retriable:      IPTablesIsErrLocked,
+}
+
+// ChainOpt configures a single chain set up by ConfigureChain
+type ChainOpt func(*chainConfig)
+
+type chainConfig struct {
+	conntrackPrelude  bool
+	neighborDiscovery bool
+	preflight         []func([]rule.Rule) error
+}
+
+func newChainConfig(opts ...ChainOpt) (cfg *chainConfig) {
+	cfg = &chainConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return
+}
+
+// WithConntrackPrelude sets if chain should allow packets of established & related connections and drop invalid
+// packets before any rules, so that rules are only evaluated against new connections.
+func WithConntrackPrelude(enable bool) ChainOpt {
+	return func(cfg *chainConfig) {
+		cfg.conntrackPrelude = enable
+	}
+}
+
+// WithICMPv6NeighborDiscovery sets if chain should always allow ICMPv6 neighbor discovery before any rules
+func WithICMPv6NeighborDiscovery(enable bool) ChainOpt {
+	return func(cfg *chainConfig) {
+		cfg.neighborDiscovery = enable
+	}
+}
+
+// WithPreflight adds a check run against the rules before any changes are made, e.g. analysis.Lint() or analysis.Warn()
+func WithPreflight(check func([]rule.Rule) error) ChainOpt {
+	return func(cfg *chainConfig) {
+		cfg.preflight = append(cfg.preflight, check)
+	}
+}
+
 func NewChainManager(opts ...ChainManagerOpt) (c ChainManager, err error) {
 	cm := newChainManagerIPTables(&chainManagerBase{
 		executor:      cmdchain.DefaultChainExecutor,
@@ -25,17 +74,30 @@ func NewChainManager(opts ...ChainManagerOpt) (c ChainManager, err error) {
 			rule.ProtocolIPv4: true,
 			rule.ProtocolIPv6: true,
 		},
-		quirks: map[Quirk]bool{},
+		quirks:         map[Quirk]bool{},
+		parallelism:    -1,
+		cleanupTimeout: 30 * time.Second,
 	})
 
 	for _, opt := range opts {
 		opt(cm)
 	}
 
+	// Address families run concurrently by default
+	if cm.parallelism < 0 {
+		cm.parallelism = len(cm.protocols)
+	}
+
+	if cm.retry != nil {
+		cm.executor = cmdchain.Retrying(cm.executor, *cm.retry)
+	}
+
 	err = cm.init()
 	return cm, err
 }
 
+// WithCustomExecutor sets executor running the commands. Executor must be safe for concurrent use unless parallelism
+// is set to 1 using WithParallelism.
 func WithCustomExecutor(executor cmdchain.Executor) ChainManagerOpt {
 	return func(c ChainManager) {
 		c.(chainManagerBaseGetter).Mut(func(cm *chainManagerBase) {
@@ -69,11 +131,87 @@ func WithChecks(check bool) ChainManagerOpt {
 	}
 }
 
+// WithParallelism sets how many independent command chains (e.g. one per address family) may run at once.
+// 0 means no limit, defaults to the number of enabled protocols. Use 1 to run them sequentially in a stable order,
+// e.g. for generating scripts.
+func WithParallelism(parallelism int) ChainManagerOpt {
+	return func(c ChainManager) {
+		c.(chainManagerBaseGetter).Mut(func(cm *chainManagerBase) {
+			cm.parallelism = parallelism
+		})
+	}
+}
+
+// WithRetry sets policy for retrying failed commands, e.g. DefaultLockRetryPolicy. Policy without classifier
+// retries when iptables fails to acquire xtables lock, see IPTablesIsErrLocked
+func WithRetry(policy cmdchain.RetryPolicy) ChainManagerOpt {
+	return func(c ChainManager) {
+		c.(chainManagerBaseGetter).Mut(func(cm *chainManagerBase) {
+			if policy.Retriable == nil {
+				policy.Retriable = IPTablesIsErrLocked
+			}
+			cm.retry = &policy
+		})
+	}
+}
+
+// WithCleanupTimeout sets how long cleanup of a partially applied change may take. Cleanup runs under its own
+// context, so that it happens also when the change was cancelled. Defaults to 30 seconds
+func WithCleanupTimeout(timeout time.Duration) ChainManagerOpt {
+	return func(c ChainManager) {
+		c.(chainManagerBaseGetter).Mut(func(cm *chainManagerBase) {
+			cm.cleanupTimeout = timeout
+		})
+	}
+}
+
+// WithLogBlocked sets log settings used for every block rule which does not specify its own.
+// Passing nil disables logging of block rules.
+func WithLogBlocked(log *rule.Log) ChainManagerOpt {
+	return func(c ChainManager) {
+		c.(chainManagerBaseGetter).Mut(func(cm *chainManagerBase) {
+			cm.logBlocked = log
+		})
+	}
+}
+
 type chainManagerBase struct {
 	executor      cmdchain.Executor
 	executeChecks bool
 	protocols     map[rule.Protocol]bool
 	quirks        map[Quirk]bool
+	logBlocked    *rule.Log
+	parallelism   int
+	retry         *cmdchain.RetryPolicy
+	// Timeout of cleanup after failed or cancelled changes
+	cleanupTimeout time.Duration
+}
+
+// enabledProtocols returns enabled protocols in a stable order
+func (c *chainManagerBase) enabledProtocols() (protocols []rule.Protocol) {
+	for _, proto := range []rule.Protocol{rule.ProtocolIPv4, rule.ProtocolIPv6} {
+		if c.protocols[proto] {
+			protocols = append(protocols, proto)
+		}
+	}
+	return
+}
+
+// forEachProtocol runs fn for every enabled protocol using cmdchain.RunParallel, errors are ordered by protocol
+func (c *chainManagerBase) forEachProtocol(ctx context.Context, fn func(context.Context, rule.Protocol) error) error {
+	var fns []func(context.Context) error
+	for _, proto := range c.enabledProtocols() {
+		proto := proto
+		fns = append(fns, func(ctx context.Context) error {
+			return fn(ctx, proto)
+		})
+	}
+	return cmdchain.RunParallel(ctx, c.parallelism, fns...)
+}
+
+// cleanupContext returns context for cleaning up after failed or cancelled changes, independent of the change's context
+func (c *chainManagerBase) cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.cleanupTimeout)
 }
 
 type chainManagerBaseGetter interface {
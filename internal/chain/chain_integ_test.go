@@ -175,22 +175,13 @@ func TestChainDockerIPTablesLegacy(t *testing.T) {
 		{
 			Direction: "input",
 			Protocol:  "tcp",
-			CIDR:      "10.123.0.1/24",
+			Source:    rule.MustParseCIDR("10.123.0.1/24"),
 			Port:      22,
 			Action:    "allow",
 		},
 		{
 			Direction: "input",
 			Protocol:  "tcp",
-			CIDR:      "0.0.0.0/0",
-			Action:    "allow",
-			StartPort: 1024,
-			EndPort:   4096,
-		},
-		{
-			Direction: "input",
-			Protocol:  "tcpv6",
-			CIDR:      "::/0",
 			Action:    "allow",
 			StartPort: 1024,
 			EndPort:   4096,
@@ -198,13 +189,6 @@ func TestChainDockerIPTablesLegacy(t *testing.T) {
 		{
 			Direction: "input",
 			Protocol:  "tcp",
-			CIDR:      "0.0.0.0/0",
-			Action:    "block",
-		},
-		{
-			Direction: "input",
-			Protocol:  "tcpv6",
-			CIDR:      "::/0",
 			Action:    "block",
 		},
 	}
@@ -285,22 +269,13 @@ func TestChainDockerIPTablesNft(t *testing.T) {
 		{
 			Direction: "input",
 			Protocol:  "tcp",
-			CIDR:      "10.123.0.1/24",
+			Source:    rule.MustParseCIDR("10.123.0.1/24"),
 			Port:      22,
 			Action:    "allow",
 		},
 		{
 			Direction: "input",
 			Protocol:  "tcp",
-			CIDR:      "0.0.0.0/0",
-			Action:    "allow",
-			StartPort: 1024,
-			EndPort:   4096,
-		},
-		{
-			Direction: "input",
-			Protocol:  "tcpv6",
-			CIDR:      "::/0",
 			Action:    "allow",
 			StartPort: 1024,
 			EndPort:   4096,
@@ -308,13 +283,6 @@ func TestChainDockerIPTablesNft(t *testing.T) {
 		{
 			Direction: "input",
 			Protocol:  "tcp",
-			CIDR:      "0.0.0.0/0",
-			Action:    "block",
-		},
-		{
-			Direction: "input",
-			Protocol:  "tcpv6",
-			CIDR:      "::/0",
 			Action:    "block",
 		},
 	}
@@ -3,10 +3,9 @@ package chain
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
-	"go.uber.org/multierr"
-
 	"github.com/ZentriaMC/swdfw/internal/cmdchain"
 	"github.com/ZentriaMC/swdfw/internal/rule"
 )
@@ -17,6 +16,7 @@ type ChainManagerIPTables struct {
 	iptablesPath       string
 	ip6tablesPath      string
 	verifyIptablesPath bool
+	wait               time.Duration
 }
 
 func newChainManagerIPTables(base *chainManagerBase) (c *ChainManagerIPTables) {
@@ -25,16 +25,20 @@ func newChainManagerIPTables(base *chainManagerBase) (c *ChainManagerIPTables) {
 		iptablesPath:       "iptables",
 		ip6tablesPath:      "ip6tables",
 		verifyIptablesPath: false,
+		wait:               time.Second,
 	}
 	return
 }
 
 func (c *ChainManagerIPTables) init() (err error) {
 	// TODO: c.verifyIptablesPath
+	if c.wait < 0 {
+		err = fmt.Errorf("iptables wait must not be negative, got %s", c.wait)
+	}
 	return
 }
 
-func (c *ChainManagerIPTables) ConfigureChain(ctx context.Context, name, parentChain, jumpTo string, rules []rule.Rule) (err error) {
+func (c *ChainManagerIPTables) ConfigureChain(ctx context.Context, name, parentChain, jumpTo string, rules []rule.Rule, opts ...ChainOpt) (err error) {
 	if err = c.chainLength(name, 0); err != nil {
 		return
 	}
@@ -45,22 +49,46 @@ func (c *ChainManagerIPTables) ConfigureChain(ctx context.Context, name, parentC
 		return
 	}
 
-	if err = c.createChain(ctx, name, tempName, jumpTo, rules); err != nil {
+	cfg := newChainConfig(opts...)
+	for _, check := range cfg.preflight {
+		if err = check(rules); err != nil {
+			err = fmt.Errorf("preflight check failed: %w", err)
+			return
+		}
+	}
+
+	if err = c.createChain(ctx, name, tempName, jumpTo, rules, cfg); err != nil {
+		err = cancelled(ctx, false, err)
+		return
+	}
+
+	// Swapping chains and cleaning up old rules is not interrupted once started, as stopping halfway would leave
+	// temporary chain behind. It is bounded by cleanup timeout instead
+	swapCtx, cancel := c.cleanupContext()
+	defer cancel()
+
+	if ctx.Err() != nil {
+		c.deleteChainOnCleanup(swapCtx, tempName)
+		err = cancelled(ctx, false, nil)
 		return
 	}
 
 	// Insert new chain jump before old one
-	_ = c.runAllProtocols(ctx, "filter", "-I", parentChain, "-g", tempName)
+	_ = c.runAllProtocols(swapCtx, "filter", "-I", parentChain, "-g", tempName)
 
-	// Remove old chain references
-	_ = c.runAllProtocols(ctx, "filter", "-D", parentChain, "-g", name)
+	// Remove old chain references, there are none on first run
+	_ = c.runAllProtocolsTolerating(swapCtx, IPTablesErrNotExist(false), "filter", "-D", parentChain, "-g", name)
 
-	if err = c.DeleteChain(ctx, name); err != nil {
-		err = fmt.Errorf("failed to clean up old rules: %w", err)
+	if err = c.DeleteChain(swapCtx, name); err != nil {
+		err = cancelled(ctx, true, fmt.Errorf("failed to clean up old rules: %w", err))
 		// TODO: not fatal
 		return
 	}
-	err = c.runAllProtocols(ctx, "filter", "-E", tempName, name)
+
+	// Change is complete once renamed, even if ctx was cancelled meanwhile
+	if err = c.runAllProtocols(swapCtx, "filter", "-E", tempName, name); err != nil {
+		err = cancelled(ctx, true, err)
+	}
 	return
 }
 
@@ -70,43 +98,48 @@ func (c *ChainManagerIPTables) InstallBaseChain(ctx context.Context, name, paren
 	}
 
 	if err = c.createChainIfNotExists(ctx, "filter", name); err != nil {
+		err = cancelled(ctx, false, err)
+		return
+	}
+
+	if ctx.Err() != nil {
+		err = cancelled(ctx, false, nil)
 		return
 	}
 
 	jump := []string{"-j", name}
-	for proto := range c.protocols {
-		rerr := cmdchain.NewCommandChain(ctx, c.prog(proto)).
+	err = c.forEachProtocol(ctx, func(ctx context.Context, proto rule.Protocol) error {
+		return cmdchain.NewCommandChain(ctx, c.prog(proto)).
 			WithExecutor(c.executor).
 			WithEnableChecks(c.executeChecks).
 			WithCheck("parent-rule-exists", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
-				return cc.WithErrInterceptor(IPTablesIsErrNotExist(false)).
+				return cc.WithTolerated(IPTablesErrNotExist(false)...).
+					WithOutput(io.Discard, nil).
 					WithNegated(true).
 					Args(c.cmdRuleExists(proto, "filter", parentChain, jump...)...)
 			}).
 			Args(c.iptables(proto, "filter", "-A", parentChain, jump...)...).
 			Run()
-
-		err = multierr.Append(err, rerr)
-	}
-
+	})
+	err = cancelled(ctx, false, err)
 	return
 }
 
 func (c *ChainManagerIPTables) DeleteChain(ctx context.Context, name string) (err error) {
-	for proto := range c.protocols {
+	err = c.forEachProtocol(ctx, func(ctx context.Context, proto rule.Protocol) error {
 		cch := cmdchain.NewCommandChain(ctx, "chain-delete").
 			WithExecutor(c.executor).
 			WithEnableChecks(c.executeChecks)
 
 		if _, ok := c.quirks[QuirkIPTablesBrokenChainCheck]; ok {
-			cch = cch.WithErrInterceptor(IPTablesIsErrNotExist(false))
+			cch = cch.WithTolerated(IPTablesErrNotExist(false)...)
 		} else {
 			cch = cch.WithCheck("chain-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
 				return c.checkChainExists(cc, proto, "filter", name, true)
 			})
 		}
 
-		rerr := cch.ArgsGroup(
+		return cch.ArgsGroup(
 			func(cc cmdchain.CommandChain) cmdchain.CommandChain {
 				return cc.
 					WithName("flush-chain").
@@ -118,8 +151,7 @@ func (c *ChainManagerIPTables) DeleteChain(ctx context.Context, name string) (er
 					Args(c.iptables(proto, "filter", "-X", name)...)
 			},
 		).Run()
-		err = multierr.Append(err, rerr)
-	}
+	})
 	return
 }
 
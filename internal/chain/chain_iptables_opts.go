@@ -1,6 +1,9 @@
 package chain
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 func VerifyIPTablesPath(verify bool) ChainManagerOpt {
 	return func(cm ChainManager) {
@@ -34,3 +37,16 @@ func IP6TablesPath(path string) ChainManagerOpt {
 		c.ip6tablesPath = path
 	}
 }
+
+// IPTablesWait sets how long iptables waits for xtables lock held by another process, rounded up to whole seconds.
+// 0 waits indefinitely, negative values are rejected by NewChainManager. Defaults to 1 second
+func IPTablesWait(wait time.Duration) ChainManagerOpt {
+	return func(cm ChainManager) {
+		c, ok := cm.(*ChainManagerIPTables)
+		if !ok {
+			panic(fmt.Errorf("IPTablesWait is valid only with iptables chain manager"))
+		}
+
+		c.wait = wait
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strconv"
+	"time"
 
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -31,7 +33,12 @@ func (c *ChainManagerIPTables) prog(proto rule.Protocol) (progName string) {
 }
 
 func (c *ChainManagerIPTables) iptables(proto rule.Protocol, table, action, chainName string, args ...string) []string {
-	return append([]string{c.prog(proto), "--wait", "1", "-t", table, action, chainName}, args...)
+	command := []string{c.prog(proto), "--wait"}
+	if c.wait > 0 {
+		seconds := (c.wait + time.Second - 1) / time.Second
+		command = append(command, strconv.FormatInt(int64(seconds), 10))
+	}
+	return append(command, append([]string{"-t", table, action, chainName}, args...)...)
 }
 
 func (c *ChainManagerIPTables) cmdChainExists(proto rule.Protocol, table string, chain string) []string {
@@ -47,8 +54,12 @@ func (c *ChainManagerIPTables) cmdCreateChain(proto rule.Protocol, table string,
 }
 
 func (c *ChainManagerIPTables) runProtocol(ctx context.Context, proto rule.Protocol, table, action, chainName string, args ...string) (err error) {
+	return c.runProtocolTolerating(ctx, proto, nil, table, action, chainName, args...)
+}
+
+func (c *ChainManagerIPTables) runProtocolTolerating(ctx context.Context, proto rule.Protocol, tolerated []cmdchain.ToleratedError, table, action, chainName string, args ...string) (err error) {
 	return cmdchain.NewCommandChain(ctx, c.prog(proto)).
-		WithErrInterceptor(IPTablesIsErrAlreadyExist(false)).
+		WithTolerated(tolerated...).
 		WithExecutor(c.executor).
 		WithEnableChecks(c.executeChecks).
 		Args(c.iptables(proto, table, action, chainName, args...)...).
@@ -56,34 +67,35 @@ func (c *ChainManagerIPTables) runProtocol(ctx context.Context, proto rule.Proto
 }
 
 func (c *ChainManagerIPTables) runAllProtocols(ctx context.Context, table, action, chainName string, args ...string) (err error) {
-	for proto := range c.protocols {
-		perr := c.runProtocol(ctx, proto, table, action, chainName, args...)
-		err = multierr.Append(err, perr)
-	}
-	return
+	return c.runAllProtocolsTolerating(ctx, nil, table, action, chainName, args...)
+}
+
+func (c *ChainManagerIPTables) runAllProtocolsTolerating(ctx context.Context, tolerated []cmdchain.ToleratedError, table, action, chainName string, args ...string) (err error) {
+	return c.forEachProtocol(ctx, func(ctx context.Context, proto rule.Protocol) error {
+		return c.runProtocolTolerating(ctx, proto, tolerated, table, action, chainName, args...)
+	})
 }
 
 func (c *ChainManagerIPTables) createChainIfNotExists(ctx context.Context, table string, chainName string) (err error) {
-	for proto := range c.protocols {
+	return c.forEachProtocol(ctx, func(ctx context.Context, proto rule.Protocol) error {
 		cch := cmdchain.NewCommandChain(ctx, c.prog(proto)).
 			WithExecutor(c.executor).
 			WithEnableChecks(c.executeChecks)
 
 		if _, ok := c.quirks[QuirkIPTablesBrokenChainCheck]; ok {
-			cch = cch.WithErrInterceptor(IPTablesIsErrNotExist(false))
+			// Chain is created unconditionally, existing one is not an error
+			cch = cch.WithTolerated(IPTablesErrAlreadyExist(false)...)
 		} else {
 			cch = cch.WithCheck("chain-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
-				return c.checkChainExists(cc, proto, table, chainName, false)
+				return c.checkChainMissing(cc, proto, table, chainName)
 			})
 		}
 
-		rerr := cch.Args(c.cmdCreateChain(proto, table, chainName)...).Run()
-		err = multierr.Append(err, rerr)
-	}
-	return
+		return cch.Args(c.cmdCreateChain(proto, table, chainName)...).Run()
+	})
 }
 
-func (c *ChainManagerIPTables) createChain(ctx context.Context, realName, tempName, jumpTo string, rules []rule.Rule) (err error) {
+func (c *ChainManagerIPTables) createChain(ctx context.Context, realName, tempName, jumpTo string, rules []rule.Rule, cfg *chainConfig) (err error) {
 	if err = c.createChainIfNotExists(ctx, "filter", tempName); err != nil {
 		err = fmt.Errorf("failed to create a firewall chain: %w", err)
 		return
@@ -91,27 +103,72 @@ func (c *ChainManagerIPTables) createChain(ctx context.Context, realName, tempNa
 
 	defer func() {
 		if err != nil {
-			derr := c.DeleteChain(context.Background(), tempName)
-			if derr != nil {
-				zap.L().Error("failed to delete chain", zap.Error(err))
-			}
+			cleanupCtx, cancel := c.cleanupContext()
+			defer cancel()
+			c.deleteChainOnCleanup(cleanupCtx, tempName)
 		}
 	}()
 
-	var rulespec []string
+	if cfg.conntrackPrelude {
+		for _, rulespec := range conntrackPrelude(realName) {
+			err = multierr.Append(err, c.runAllProtocols(ctx, "filter", "-A", tempName, rulespec...))
+		}
+	}
+
+	var rulespecs [][]string
+	var logspecs [][]string
 	var rerr error
-	for _, rule := range rules {
-		proto := rule.Proto()
-		if _, ok := c.protocols[proto]; !ok {
-			continue
+
+	if _, ok := c.protocols[rule.ProtocolIPv6]; ok && cfg.neighborDiscovery {
+		for _, ndRule := range rule.ICMPv6NeighborDiscoveryRules() {
+			if rulespecs, rerr = ndRule.ToRulespecs(realName); rerr != nil {
+				err = multierr.Append(err, rerr)
+				continue
+			}
+			for _, rulespec := range rulespecs {
+				err = multierr.Append(err, c.runProtocol(ctx, rule.ProtocolIPv6, "filter", "-A", tempName, rulespec...))
+			}
+		}
+	}
+
+	order := rule.PriorityOrder(rules)
+
+	var familyRules []rule.Rule
+	for _, idx := range order {
+		if cerr := ctx.Err(); cerr != nil {
+			err = multierr.Append(err, cerr)
+			return
 		}
 
-		if rulespec, rerr = rule.ToRulespec(realName); rerr != nil {
+		r := rules[idx]
+		if familyRules, rerr = r.SplitFamilies(); rerr != nil {
 			err = multierr.Append(err, rerr)
 			continue
 		}
 
-		err = multierr.Append(err, c.runProtocol(ctx, proto, "filter", "-A", tempName, rulespec...))
+		for _, familyRule := range familyRules {
+			proto := familyRule.Proto()
+			if _, ok := c.protocols[proto]; !ok {
+				continue
+			}
+
+			if logspecs, rerr = familyRule.ToLogRulespecs(realName, idx, c.logBlocked); rerr != nil {
+				err = multierr.Append(err, rerr)
+				continue
+			}
+
+			if rulespecs, rerr = familyRule.ToRulespecs(realName); rerr != nil {
+				err = multierr.Append(err, rerr)
+				continue
+			}
+
+			for i, rulespec := range rulespecs {
+				if logspecs != nil {
+					err = multierr.Append(err, c.runProtocol(ctx, proto, "filter", "-A", tempName, logspecs[i]...))
+				}
+				err = multierr.Append(err, c.runProtocol(ctx, proto, "filter", "-A", tempName, rulespec...))
+			}
+		}
 	}
 
 	if jumpTo != "" {
@@ -122,9 +179,33 @@ func (c *ChainManagerIPTables) createChain(ctx context.Context, realName, tempNa
 	return
 }
 
+// deleteChainOnCleanup deletes chain left behind by a failed or cancelled change
+func (c *ChainManagerIPTables) deleteChainOnCleanup(ctx context.Context, name string) {
+	if err := c.DeleteChain(ctx, name); err != nil {
+		zap.L().Error("failed to delete chain", zap.String("chain", name), zap.Error(err))
+	}
+}
+
+func conntrackPrelude(chainName string) [][]string {
+	comment := []string{"-m", "comment", "--comment", fmt.Sprintf("Autogenerated conntrack prelude using swdfw from '%s'", chainName)}
+	return [][]string{
+		append([]string{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "RETURN"}, comment...),
+		append([]string{"-m", "conntrack", "--ctstate", "INVALID", "-j", "DROP"}, comment...),
+	}
+}
+
+// checkChainExists passes when chain exists, missing chain short circuits when short is set
 func (c *ChainManagerIPTables) checkChainExists(cc cmdchain.CommandChain, proto rule.Protocol, table, chainName string, short bool) cmdchain.CommandChain {
 	return cc.
-		WithErrInterceptor(IPTablesIsErrNotExist(short)).
+		WithTolerated(IPTablesErrNotExist(short)...).
+		WithOutput(io.Discard, nil).
+		Args(c.cmdChainExists(proto, table, chainName)...)
+}
+
+// checkChainMissing passes when chain does not exist, existing chain short circuits. Other errors fail the check.
+func (c *ChainManagerIPTables) checkChainMissing(cc cmdchain.CommandChain, proto rule.Protocol, table, chainName string) cmdchain.CommandChain {
+	return cc.
+		WithTolerated(IPTablesErrNotExist(false)...).
 		WithOutput(io.Discard, nil).
 		WithNegated(true).
 		Args(c.cmdChainExists(proto, table, chainName)...)
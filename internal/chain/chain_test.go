@@ -2,11 +2,17 @@ package chain_test
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ZentriaMC/swdfw/internal/analysis"
 	"github.com/ZentriaMC/swdfw/internal/chain"
 	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/golden"
 	"github.com/ZentriaMC/swdfw/internal/rule"
 )
 
@@ -31,27 +37,27 @@ func TestChain(t *testing.T) {
 	rules := []rule.Rule{
 		{
 			Protocol: "tcp",
-			CIDR:     "10.123.0.1/24",
+			Source:   rule.MustParseCIDR("10.123.0.1/24"),
 			Port:     22,
 			Action:   "allow",
 		},
 		{
 			Protocol:  "tcp",
-			CIDR:      "0.0.0.0/0",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
 			Action:    "allow",
 			StartPort: 1024,
 			EndPort:   4096,
 		},
 		{
 			Protocol:  "tcpv6",
-			CIDR:      "::/0",
+			Source:    rule.MustParseCIDR("::/0"),
 			Action:    "allow",
 			StartPort: 1024,
 			EndPort:   4096,
 		},
 		{
 			Protocol: "tcp",
-			CIDR:     "0.0.0.0/0",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
 			Action:   "block",
 		},
 	}
@@ -68,6 +74,439 @@ func TestChain(t *testing.T) {
 		t.Fatalf("failed to replace chain: %s", err)
 	}
 
-	script := sg.Script()
-	fmt.Println(script)
+	golden.Assert(t, filepath.Join("scripts", "chain.sh"), stableScript(sg.Script()))
+}
+
+func TestChainConntrackPrelude(t *testing.T) {
+	var commands []string
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		commands = append(commands, strings.Join(args, " "))
+		return nil
+	}
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(collectingExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.WithChecks(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	rules := []rule.Rule{
+		{
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
+			Action:    "allow",
+			StartPort: 22,
+		},
+	}
+
+	err = c.ConfigureChain(context.Background(), "prelude", "SWDFW-INPUT", "", rules, chain.WithConntrackPrelude(true))
+	if err != nil {
+		t.Fatalf("failed to replace chain: %s", err)
+	}
+
+	var appended []string
+	for _, command := range commands {
+		if strings.Contains(command, " -A prelude:") {
+			appended = append(appended, command)
+		}
+	}
+
+	if len(appended) != 3 {
+		t.Fatalf("expected 3 rules to be appended, got %d", len(appended))
+	}
+
+	if !strings.Contains(appended[0], "--ctstate ESTABLISHED,RELATED -j RETURN") {
+		t.Errorf("expected first rule to allow established connections, got '%s'", appended[0])
+	}
+
+	if !strings.Contains(appended[1], "--ctstate INVALID -j DROP") {
+		t.Errorf("expected second rule to drop invalid packets, got '%s'", appended[1])
+	}
+}
+
+func TestChainDualStack(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	// Address families are configured in parallel
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, strings.Join(args, " "))
+		return nil
+	}
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(collectingExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4, rule.ProtocolIPv6),
+		chain.WithChecks(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	rules := []rule.Rule{
+		{
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("10.0.0.0/8", "2001:db8::/32"),
+			Action:    "allow",
+			StartPort: 22,
+		},
+		{
+			Protocol: "tcp",
+			Action:   "block",
+		},
+	}
+
+	err = c.ConfigureChain(context.Background(), "dualstack", "SWDFW-INPUT", "", rules)
+	if err != nil {
+		t.Fatalf("failed to replace chain: %s", err)
+	}
+
+	appended := map[string][]string{}
+	for _, command := range commands {
+		if strings.Contains(command, " -A dualstack:") {
+			prog := strings.SplitN(command, " ", 2)[0]
+			appended[prog] = append(appended[prog], command)
+		}
+	}
+
+	for prog, expected := range map[string][]string{"iptables": {"-s 10.0.0.0/8", "-s 0.0.0.0/0"}, "ip6tables": {"-s 2001:db8::/32", "-s ::/0"}} {
+		if len(appended[prog]) != len(expected) {
+			t.Errorf("expected %d rules appended using %s, got %d", len(expected), prog, len(appended[prog]))
+			continue
+		}
+
+		for i, source := range expected {
+			if !strings.Contains(appended[prog][i], source) {
+				t.Errorf("expected rule '%s' to match '%s'", appended[prog][i], source)
+			}
+		}
+	}
+}
+
+func TestChainPriority(t *testing.T) {
+	var commands []string
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		commands = append(commands, strings.Join(args, " "))
+		return nil
+	}
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(collectingExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.WithChecks(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	rules := []rule.Rule{
+		{
+			ID:       "catch-all",
+			Protocol: "tcp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "block",
+			Priority: 100,
+		},
+		{
+			ID:        "ssh",
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("10.0.0.0/8"),
+			StartPort: 22,
+			Action:    "allow",
+		},
+	}
+
+	err = c.ConfigureChain(context.Background(), "priority", "SWDFW-INPUT", "", rules)
+	if err != nil {
+		t.Fatalf("failed to replace chain: %s", err)
+	}
+
+	var ids []string
+	for _, command := range commands {
+		if strings.Contains(command, " -A priority:") {
+			_, id, _, _ := rule.ParseComment(command[strings.LastIndex(command, "Autogenerated"):])
+			ids = append(ids, id)
+		}
+	}
+
+	if strings.Join(ids, ",") != "ssh,catch-all" {
+		t.Errorf("unexpected rule installation order: %v", ids)
+	}
+}
+
+func TestChainPreflight(t *testing.T) {
+	var commands []string
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		commands = append(commands, strings.Join(args, " "))
+		return nil
+	}
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(collectingExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.WithChecks(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	rules := []rule.Rule{
+		{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"},
+		{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8"), StartPort: 22, Action: "allow"},
+	}
+
+	err = c.ConfigureChain(context.Background(), "preflight", "SWDFW-INPUT", "", rules, chain.WithPreflight(analysis.Lint()))
+	var lintErr *analysis.LintError
+	if !errors.As(err, &lintErr) {
+		t.Fatalf("expected lint error, got %v", err)
+	}
+
+	if len(commands) > 0 {
+		t.Errorf("no commands should run when preflight fails, got %v", commands)
+	}
+}
+
+func TestChainExistenceChecks(t *testing.T) {
+	cases := []struct {
+		name     string
+		check    *cmdchain.ChainExecError
+		fails    bool
+		executed []string
+	}{
+		{
+			name:     "missing",
+			check:    &cmdchain.ChainExecError{Stderr_: "iptables: No chain/target/match by that name.\n", Status: 1},
+			executed: []string{"-N", "-A"},
+		},
+		{
+			name:  "existing",
+			check: nil,
+		},
+		{
+			name:  "permission denied",
+			check: &cmdchain.ChainExecError{Stderr_: "iptables: Permission denied (you must be root).\n", Status: 4},
+			fails: true,
+		},
+		{
+			name:  "missing binary",
+			check: &cmdchain.ChainExecError{Stderr_: "exec: \"iptables\": executable file not found in $PATH", Status: -1},
+			fails: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var executed []string
+			var executor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+				if action := args[5]; action == "-S" || action == "-C" {
+					if tc.check == nil {
+						return nil
+					}
+					checkErr := *tc.check
+					checkErr.Args = args
+					return &checkErr
+				}
+				executed = append(executed, args[5])
+				return nil
+			}
+
+			c, err := chain.NewChainManager(
+				chain.WithCustomExecutor(executor),
+				chain.WithProtocols(rule.ProtocolIPv4),
+			)
+			if err != nil {
+				t.Fatalf("failed to initialize chainmanager: %s", err)
+			}
+
+			err = c.InstallBaseChain(context.Background(), "SWDFW-INPUT", "INPUT")
+			var cmdErr *cmdchain.ChainExecError
+			if tc.fails {
+				if !errors.As(err, &cmdErr) || cmdErr.Stderr() != tc.check.Stderr() {
+					t.Errorf("expected check error with stderr %q, got %v", tc.check.Stderr(), err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if strings.Join(executed, " ") != strings.Join(tc.executed, " ") {
+				t.Errorf("expected %v to be executed, got %v", tc.executed, executed)
+			}
+		})
+	}
+}
+
+func TestChainLockRetry(t *testing.T) {
+	var commands []string
+	var lockedExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		commands = append(commands, strings.Join(args, " "))
+		if len(commands) == 1 {
+			return &cmdchain.ChainExecError{
+				Args:    args,
+				Stderr_: "Another app is currently holding the xtables lock. Stopped waiting after 5s.\n",
+				Status:  4,
+			}
+		}
+		return nil
+	}
+
+	policy := chain.DefaultLockRetryPolicy
+	policy.InitialBackoff = time.Millisecond
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(lockedExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.WithChecks(false),
+		chain.WithRetry(policy),
+		chain.IPTablesWait(4500*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	if err := c.DeleteChain(context.Background(), "locked"); err != nil {
+		t.Fatalf("expected command to succeed after retry, got %s", err)
+	}
+
+	expected := "iptables --wait 5 -t filter -F locked"
+	if len(commands) != 3 || commands[0] != expected || commands[1] != expected {
+		t.Errorf("expected flush to be retried once, got %v", commands)
+	}
+}
+
+func TestChainIPTablesWait(t *testing.T) {
+	cases := []struct {
+		wait     time.Duration
+		expected string
+	}{
+		{wait: 0, expected: "iptables --wait -t filter -F waiting"},
+		{wait: time.Second, expected: "iptables --wait 1 -t filter -F waiting"},
+		{wait: 1500 * time.Millisecond, expected: "iptables --wait 2 -t filter -F waiting"},
+	}
+
+	for _, c := range cases {
+		var commands []string
+		cm, err := chain.NewChainManager(
+			chain.WithCustomExecutor(func(ctx context.Context, args ...string) error {
+				commands = append(commands, strings.Join(args, " "))
+				return nil
+			}),
+			chain.WithProtocols(rule.ProtocolIPv4),
+			chain.WithChecks(false),
+			chain.IPTablesWait(c.wait),
+		)
+		if err != nil {
+			t.Fatalf("failed to initialize chainmanager: %s", err)
+		}
+
+		if err := cm.DeleteChain(context.Background(), "waiting"); err != nil {
+			t.Fatalf("failed to delete chain: %s", err)
+		}
+
+		if len(commands) == 0 || commands[0] != c.expected {
+			t.Errorf("wait %s: expected '%s', got %v", c.wait, c.expected, commands)
+		}
+	}
+
+	if _, err := chain.NewChainManager(chain.IPTablesWait(-time.Second)); err == nil {
+		t.Error("expected negative wait to be rejected")
+	}
+}
+
+func TestChainCancelled(t *testing.T) {
+	var rules []rule.Rule
+	for port := uint16(1); port <= 50; port++ {
+		rules = append(rules, rule.Rule{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Ports: rule.Ports{{Start: port, End: port}}, Action: "allow"})
+	}
+
+	cases := []struct {
+		name     string
+		cancelOn string
+		failOn   string
+		expected error
+	}{
+		{name: "before-swap", cancelOn: " -A cancelled:", expected: chain.ErrCancelledBeforeSwap},
+		{name: "after-swap", cancelOn: " -I SWDFW-INPUT"},
+		{name: "after-swap-cleanup-failed", cancelOn: " -I SWDFW-INPUT", failOn: " -S cancelled 1", expected: chain.ErrCancelledAfterSwap},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			rg := chain.NewRulesetGenerator()
+			var commands []string
+			var cancellingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+				command := strings.Join(args, " ")
+				commands = append(commands, command)
+				if strings.Contains(command, tc.cancelOn) {
+					cancel()
+				}
+				if tc.failOn != "" && strings.Contains(command, tc.failOn) {
+					return &cmdchain.ChainExecError{Args: args, Status: 4, Stderr_: "Permission denied (you must be root)"}
+				}
+				return rg.Executor()(ctx, args...)
+			}
+
+			c, err := chain.NewChainManager(
+				chain.WithCustomExecutor(cancellingExecutor),
+				chain.WithProtocols(rule.ProtocolIPv4),
+			)
+			if err != nil {
+				t.Fatalf("failed to initialize chainmanager: %s", err)
+			}
+
+			if err := c.InstallBaseChain(context.Background(), "SWDFW-INPUT", "INPUT"); err != nil {
+				t.Fatalf("failed to install base chain: %s", err)
+			}
+
+			err = c.ConfigureChain(ctx, "cancelled", "SWDFW-INPUT", "", rules)
+			if tc.expected == nil && err != nil {
+				t.Fatalf("expected change completed after swap to succeed, got %v", err)
+			} else if tc.expected != nil && (!errors.Is(err, tc.expected) || !errors.Is(err, context.Canceled)) {
+				t.Fatalf("expected %s, got %v", tc.expected, err)
+			}
+
+			appended := 0
+			for _, command := range commands {
+				if strings.Contains(command, " -A cancelled:") {
+					appended++
+				}
+			}
+			restore := rg.Restore(rule.ProtocolIPv4)
+			// Failed cleanup leaves new rules in temporary chain
+			if tc.failOn == "" && strings.Contains(restore, "cancelled:") {
+				t.Errorf("expected temporary chain to be cleaned up:\n%s", restore)
+			}
+
+			swapped := strings.Contains(restore, "-A SWDFW-INPUT -g cancelled")
+			if tc.expected != chain.ErrCancelledBeforeSwap && !swapped {
+				t.Errorf("expected swap to be completed:\n%s", restore)
+			} else if tc.expected == chain.ErrCancelledBeforeSwap && (swapped || appended >= len(rules)) {
+				t.Errorf("expected cancellation to stop appending rules before swap:\n%s", restore)
+			}
+		})
+	}
+}
+
+func TestDeleteChainCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(chain.NewRulesetGenerator().Executor()),
+		chain.WithProtocols(rule.ProtocolIPv4),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	err = c.DeleteChain(ctx, "cancelled")
+	if !errors.Is(err, context.Canceled) || errors.Is(err, chain.ErrCancelledBeforeSwap) {
+		t.Errorf("expected plain context error, got %v", err)
+	}
 }
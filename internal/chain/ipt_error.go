@@ -11,41 +11,38 @@ const (
 	msgIPTNoRuleExist  = "Bad rule (does a matching rule exist in that chain?).\n"
 	msgIPTNoChainExist = "No chain/target/match by that name.\n"
 	msgIPTChainExist   = "Chain already exists.\n"
+
+	msgIPTChainNotEmpty   = "Directory not empty.\n"
+	msgIPTChainReferenced = "Too many links.\n"
+	msgIPTRenameExist     = "File exists.\n"
+	msgIPTIndexTooBig     = "Index of insertion too big.\n"
+	msgIPTBuiltinChain    = "Can't delete built-in chain.\n"
+
+	msgIPTLocked = "holding the xtables lock"
+	// iptables exit status for resource problems, e.g. xtables lock being held for longer than --wait
+	iptStatusResourceProblem = 4
 )
 
-// This interceptor checks if iptables reported missing rule/chain and passes
-var IPTablesIsErrNotExist = func(short bool) cmdchain.ErrInterceptor {
-	return func(err error) error {
-		var cmdErr *cmdchain.ChainExecError
-		if !errors.As(err, &cmdErr) || cmdErr.ExitStatus() != 1 {
-			return err
-		}
-
-		if !strings.Contains(cmdErr.Stderr(), msgIPTNoRuleExist) && !strings.Contains(cmdErr.Stderr(), msgIPTNoChainExist) {
-			return err
-		}
-
-		if short {
-			return cmdchain.ErrShortCircuit
-		}
-		return nil
+// IPTablesErrNotExist describes iptables reporting missing rule/chain
+func IPTablesErrNotExist(short bool) []cmdchain.ToleratedError {
+	return []cmdchain.ToleratedError{
+		{Status: 1, Stderr: msgIPTNoRuleExist, Short: short},
+		{Status: 1, Stderr: msgIPTNoChainExist, Short: short},
+	}
+}
+
+// IPTablesErrAlreadyExist describes iptables reporting existing chain
+func IPTablesErrAlreadyExist(short bool) []cmdchain.ToleratedError {
+	return []cmdchain.ToleratedError{
+		{Status: 1, Stderr: msgIPTChainExist, Short: short},
 	}
 }
 
-var IPTablesIsErrAlreadyExist = func(short bool) cmdchain.ErrInterceptor {
-	return func(err error) error {
-		var cmdErr *cmdchain.ChainExecError
-		if !errors.As(err, &cmdErr) || cmdErr.ExitStatus() != 1 {
-			return err
-		}
-
-		if !strings.Contains(cmdErr.Stderr(), msgIPTChainExist) {
-			return err
-		}
-
-		if short {
-			return cmdchain.ErrShortCircuit
-		}
-		return nil
+// IPTablesIsErrLocked returns whether iptables gave up waiting for xtables lock held by another process
+func IPTablesIsErrLocked(err error) bool {
+	var cmdErr *cmdchain.ChainExecError
+	if !errors.As(err, &cmdErr) {
+		return false
 	}
+	return cmdErr.ExitStatus() == iptStatusResourceProblem && strings.Contains(cmdErr.Stderr(), msgIPTLocked)
 }
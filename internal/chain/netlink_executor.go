@@ -0,0 +1,431 @@
+package chain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
+
+	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+var nftBuiltinHooks = map[string]*nftables.ChainHook{
+	"INPUT":   nftables.ChainHookInput,
+	"FORWARD": nftables.ChainHookForward,
+	"OUTPUT":  nftables.ChainHookOutput,
+}
+
+const (
+	// Rule user data type holding digest of the iptables rulespec the rule was translated from. Types unknown to
+	// nft and iptables-nft are ignored by them.
+	nftUserdataRulespecDigest userdata.Type = 0xf0
+	// Prefix of rulespecs standing for rules loaded from the kernel, followed by hex encoded digest
+	nftLoadedRulePrefix = "\x00digest:"
+)
+
+// NetlinkExecutor executes iptables commands generated by ChainManager in-process using nf_tables netlink API,
+// without iptables or nft binaries. Rules are kept in 'swdfw_<table>' nftables tables, same as in RulesetGenerator.NFT
+// output. Every command changing rules is applied as a single transaction touching only the affected chain and rules,
+// thus meter state (rate limits, connection counts) is kept. Renaming a chain recreates it with its rules under the
+// new name and replaces rules referring to it.
+//
+// Tables are loaded from the kernel on first use. Rules are recognized by a digest of their rulespec kept in rule
+// user data, thus tables containing rules created by other means are refused. Rules loaded from the kernel can be
+// checked and deleted, but not recreated by renaming chains.
+type NetlinkExecutor struct {
+	mu     sync.Mutex
+	opts   []nftables.ConnOption
+	model  *RulesetGenerator
+	tables map[rule.Protocol]map[string]*nlTable
+}
+
+// nlTable is kernel state of a table not recorded by the model
+type nlTable struct {
+	// Table and its built-in chains exist in the kernel
+	exists bool
+	// Named meters existing in the kernel, rules using them share their state
+	meters map[string]*nftables.Set
+}
+
+// NewNetlinkExecutor creates executor using netlink connections created with given options, e.g. nftables.WithNetNSFd
+func NewNetlinkExecutor(opts ...nftables.ConnOption) *NetlinkExecutor {
+	return &NetlinkExecutor{
+		opts:   opts,
+		model:  NewRulesetGenerator(),
+		tables: map[rule.Protocol]map[string]*nlTable{},
+	}
+}
+
+func (e *NetlinkExecutor) Executor() cmdchain.Executor {
+	return func(ctx context.Context, command ...string) (err error) {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		var cmd *iptCommand
+		if cmd, err = parseIPTCommand(command); err != nil {
+			return
+		}
+		if err = e.load(cmd.proto, cmd.tableName); err != nil {
+			err = fmt.Errorf("failed to load table '%s': %w", cmd.tableName, err)
+			return
+		}
+		if !cmd.mutating() {
+			return e.model.apply(cmd)
+		}
+
+		before := e.model.tables[cmd.proto][cmd.tableName].clone()
+		if err = e.model.apply(cmd); err != nil {
+			return
+		}
+
+		if err = e.commit(ctx, cmd, before); err != nil {
+			e.model.tables[cmd.proto][cmd.tableName] = before
+			err = fmt.Errorf("failed to apply %v: %w", command, err)
+		}
+		return
+	}
+}
+
+func (e *NetlinkExecutor) conn() (*nftables.Conn, error) {
+	return nftables.New(e.opts...)
+}
+
+func nftTable(proto rule.Protocol, tableName string) *nftables.Table {
+	table := &nftables.Table{Name: nftTableName(tableName), Family: nftables.TableFamilyIPv4}
+	if proto == rule.ProtocolIPv6 {
+		table.Family = nftables.TableFamilyIPv6
+	}
+	return table
+}
+
+// load records chains and rules of the nftables table into the model, unless it was loaded already
+func (e *NetlinkExecutor) load(proto rule.Protocol, tableName string) (err error) {
+	if e.tables[proto][tableName] != nil {
+		return
+	}
+
+	model, ok := e.model.table(proto, tableName)
+	if !ok {
+		// Unsupported table, command fails like in RulesetGenerator
+		return
+	}
+
+	var conn *nftables.Conn
+	if conn, err = e.conn(); err != nil {
+		return
+	}
+	defer func() { _ = conn.CloseLasting() }()
+
+	state := &nlTable{meters: map[string]*nftables.Set{}}
+	table := nftTable(proto, tableName)
+
+	var tables []*nftables.Table
+	if tables, err = conn.ListTablesOfFamily(table.Family); err != nil {
+		return
+	}
+
+	found := false
+	for _, t := range tables {
+		found = found || t.Name == table.Name
+	}
+
+	if found {
+		if err = e.loadChains(conn, table, model, state); err != nil {
+			return
+		}
+
+		var sets []*nftables.Set
+		if sets, err = conn.GetSets(table); err != nil {
+			return
+		}
+		for _, set := range sets {
+			if !set.Anonymous {
+				state.meters[set.Name] = set
+			}
+		}
+	}
+
+	if e.tables[proto] == nil {
+		e.tables[proto] = map[string]*nlTable{}
+	}
+	e.tables[proto][tableName] = state
+	return
+}
+
+func (e *NetlinkExecutor) loadChains(conn *nftables.Conn, table *nftables.Table, model *iptTable, state *nlTable) (err error) {
+	var chains []*nftables.Chain
+	if chains, err = conn.ListChainsOfTableFamily(table.Family); err != nil {
+		return
+	}
+
+	builtins := 0
+	var loaded []*nftables.Chain
+	for _, ch := range chains {
+		if ch.Table.Name != table.Name {
+			continue
+		}
+
+		if existing, ok := model.chains[ch.Name]; ok && existing.builtin {
+			builtins++
+		} else {
+			model.chains[ch.Name] = &iptChain{}
+		}
+		loaded = append(loaded, ch)
+	}
+	// Missing built-in chains are created along with the next change
+	state.exists = builtins == len(nftBuiltinHooks)
+
+	for _, ch := range loaded {
+		var nftRules []*nftables.Rule
+		if nftRules, err = conn.GetRules(table, ch); err != nil {
+			return
+		}
+
+		for i, nr := range nftRules {
+			digest := userdata.Get(nr.UserData, nftUserdataRulespecDigest)
+			if len(digest) == 0 {
+				err = fmt.Errorf("rule %d in chain '%s' was not created by swdfw", i+1, ch.Name)
+				return
+			}
+			model.chains[ch.Name].rules = append(model.chains[ch.Name].rules, nftLoadedRule(digest, nr.Exprs))
+		}
+	}
+	return
+}
+
+// nftLoadedRule returns rulespec standing for a rule loaded from the kernel, keeping its jump or goto target
+func nftLoadedRule(digest []byte, exprs []expr.Any) (rulespec []string) {
+	rulespec = []string{nftLoadedRulePrefix + hex.EncodeToString(digest)}
+	for _, e := range exprs {
+		if verdict, ok := e.(*expr.Verdict); ok && verdict.Chain != "" {
+			if verdict.Kind == expr.VerdictGoto {
+				rulespec = append(rulespec, "-g", verdict.Chain)
+			} else {
+				rulespec = append(rulespec, "-j", verdict.Chain)
+			}
+		}
+	}
+	return
+}
+
+// nftRulespecDigest returns digest of a rulespec, rules loaded from the kernel carry it already
+func nftRulespecDigest(rulespec []string) []byte {
+	if len(rulespec) > 0 && strings.HasPrefix(rulespec[0], nftLoadedRulePrefix) {
+		digest, _ := hex.DecodeString(strings.TrimPrefix(rulespec[0], nftLoadedRulePrefix))
+		return digest
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(rulespec, "\x00")))
+	return sum[:16]
+}
+
+// sameRulespec compares recorded rule with a rulespec, rules loaded from the kernel are compared by digest
+func sameRulespec(recorded, rulespec []string) bool {
+	if len(recorded) > 0 && strings.HasPrefix(recorded[0], nftLoadedRulePrefix) {
+		return string(nftRulespecDigest(recorded)) == string(nftRulespecDigest(rulespec))
+	}
+	return strings.Join(recorded, "\x00") == strings.Join(rulespec, "\x00")
+}
+
+// changedRule returns index of the first rule differing between rule lists
+func changedRule(before, after [][]string) int {
+	for i := range before {
+		if i >= len(after) || strings.Join(before[i], "\x00") != strings.Join(after[i], "\x00") {
+			return i
+		}
+	}
+	return len(before)
+}
+
+// commit applies change made to the model by a command to the kernel, before is the table prior to the change
+func (e *NetlinkExecutor) commit(ctx context.Context, cmd *iptCommand, before *iptTable) (err error) {
+	// Connection per transaction discards queued messages when building it fails
+	var conn *nftables.Conn
+	if conn, err = e.conn(); err != nil {
+		return
+	}
+	defer func() { _ = conn.CloseLasting() }()
+
+	state := e.tables[cmd.proto][cmd.tableName]
+	after := e.model.tables[cmd.proto][cmd.tableName]
+	table := nftTable(cmd.proto, cmd.tableName)
+
+	b := &nftBuilder{conn: conn, table: table, family: nftFamily(cmd.proto), meters: map[string]*nftables.Set{}}
+	for name, set := range state.meters {
+		b.meters[name] = set
+	}
+
+	if !state.exists {
+		conn.AddTable(table)
+		for _, chainName := range iptBuiltinChains[cmd.tableName] {
+			policy := nftables.ChainPolicyAccept
+			conn.AddChain(&nftables.Chain{
+				Name:     chainName,
+				Table:    table,
+				Type:     nftables.ChainTypeFilter,
+				Hooknum:  nftBuiltinHooks[chainName],
+				Priority: nftables.ChainPriorityFilter,
+				Policy:   &policy,
+			})
+		}
+	}
+
+	chain := &nftables.Chain{Name: cmd.chainName, Table: table}
+	switch cmd.action {
+	case "-N":
+		// Unlike in scripts, names are not restricted to identifiers, thus temporary chains work as is
+		conn.AddChain(chain)
+	case "-A", "-I":
+		position := changedRule(before.chains[cmd.chainName].rules, after.chains[cmd.chainName].rules)
+		var nr *nftables.Rule
+		if nr, err = b.rule(chain, after.chains[cmd.chainName].rules[position]); err != nil {
+			return
+		}
+
+		if position == len(before.chains[cmd.chainName].rules) {
+			conn.AddRule(nr)
+		} else {
+			// Rule is inserted before the one at its position
+			if position > 0 {
+				var handles []uint64
+				if handles, err = e.handles(conn, table, before, cmd.chainName); err != nil {
+					return
+				}
+				nr.Position = handles[position]
+			}
+			conn.InsertRule(nr)
+		}
+	case "-D":
+		position := changedRule(before.chains[cmd.chainName].rules, after.chains[cmd.chainName].rules)
+		var handles []uint64
+		if handles, err = e.handles(conn, table, before, cmd.chainName); err != nil {
+			return
+		}
+		if err = conn.DelRule(&nftables.Rule{Table: table, Chain: chain, Handle: handles[position]}); err != nil {
+			return
+		}
+	case "-F":
+		conn.FlushChain(chain)
+	case "-X":
+		conn.DelChain(chain)
+	case "-E":
+		if err = e.rename(conn, b, before, after, cmd.chainName, cmd.args[0]); err != nil {
+			return
+		}
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = conn.Flush(); err != nil {
+		return
+	}
+
+	state.exists = true
+	state.meters = b.meters
+	return
+}
+
+// rename queues recreating a chain under new name along with its rules and replacing rules referring to it
+func (e *NetlinkExecutor) rename(conn *nftables.Conn, b *nftBuilder, before, after *iptTable, oldName, newName string) (err error) {
+	oldChain := &nftables.Chain{Name: oldName, Table: b.table}
+	newChain := conn.AddChain(&nftables.Chain{Name: newName, Table: b.table})
+
+	var nr *nftables.Rule
+	for _, r := range after.chains[newName].rules {
+		if nr, err = b.rule(newChain, r); err != nil {
+			return
+		}
+		conn.AddRule(nr)
+	}
+
+	var chainNames []string
+	for chainName := range after.chains {
+		if chainName != newName {
+			chainNames = append(chainNames, chainName)
+		}
+	}
+	sort.Strings(chainNames)
+
+	for _, chainName := range chainNames {
+		var handles []uint64
+		for i, r := range after.chains[chainName].rules {
+			if strings.Join(r, "\x00") == strings.Join(before.chains[chainName].rules[i], "\x00") {
+				continue
+			}
+
+			if handles == nil {
+				if handles, err = e.handles(conn, b.table, before, chainName); err != nil {
+					return
+				}
+			}
+			if nr, err = b.rule(&nftables.Chain{Name: chainName, Table: b.table}, r); err != nil {
+				return
+			}
+			nr.Handle = handles[i]
+			conn.ReplaceRule(nr)
+		}
+	}
+
+	conn.FlushChain(oldChain)
+	conn.DelChain(oldChain)
+	return
+}
+
+// handles returns handles of rules in a chain, in the same order as the rules recorded in the table
+func (e *NetlinkExecutor) handles(conn *nftables.Conn, table *nftables.Table, recorded *iptTable, chainName string) (handles []uint64, err error) {
+	var nftRules []*nftables.Rule
+	if nftRules, err = conn.GetRules(table, &nftables.Chain{Name: chainName, Table: table}); err != nil {
+		return
+	}
+
+	if len(nftRules) != len(recorded.chains[chainName].rules) {
+		err = fmt.Errorf("chain '%s' has %d rules, expected %d", chainName, len(nftRules), len(recorded.chains[chainName].rules))
+		return
+	}
+
+	for _, nr := range nftRules {
+		handles = append(handles, nr.Handle)
+	}
+	return
+}
+
+// rule translates rulespec into nftables rule of given chain
+func (b *nftBuilder) rule(chain *nftables.Chain, rulespec []string) (nr *nftables.Rule, err error) {
+	if len(rulespec) > 0 && strings.HasPrefix(rulespec[0], nftLoadedRulePrefix) {
+		err = fmt.Errorf("rule loaded from kernel in chain '%s' cannot be recreated", chain.Name)
+		return
+	}
+
+	var parsed *nftRule
+	if parsed, err = parseNFTRule(b.family, rulespec); err != nil {
+		err = fmt.Errorf("failed to translate rule %v in chain '%s': %w", rulespec, chain.Name, err)
+		return
+	}
+
+	nr = &nftables.Rule{Table: b.table, Chain: chain}
+	for _, component := range parsed.components {
+		if nr.Exprs, err = component.exprs(b, nr.Exprs); err != nil {
+			err = fmt.Errorf("failed to translate rule %v in chain '%s': %w", rulespec, chain.Name, err)
+			return
+		}
+	}
+
+	if parsed.comment != "" {
+		nr.UserData = userdata.AppendString(nr.UserData, userdata.TypeComment, parsed.comment)
+	}
+	nr.UserData = userdata.Append(nr.UserData, nftUserdataRulespecDigest, nftRulespecDigest(rulespec))
+	return
+}
@@ -0,0 +1,335 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	nftProtocolNumbers = map[string]uint8{
+		"icmp":    unix.IPPROTO_ICMP,
+		"tcp":     unix.IPPROTO_TCP,
+		"udp":     unix.IPPROTO_UDP,
+		"gre":     unix.IPPROTO_GRE,
+		"esp":     unix.IPPROTO_ESP,
+		"ah":      unix.IPPROTO_AH,
+		"icmpv6":  unix.IPPROTO_ICMPV6,
+		"sctp":    unix.IPPROTO_SCTP,
+		"udplite": unix.IPPROTO_UDPLITE,
+	}
+	nftStateBits = map[string]uint32{
+		"invalid":     expr.CtStateBitINVALID,
+		"established": expr.CtStateBitESTABLISHED,
+		"related":     expr.CtStateBitRELATED,
+		"new":         expr.CtStateBitNEW,
+		"untracked":   expr.CtStateBitUNTRACKED,
+	}
+	nftLimitUnits = map[string]expr.LimitTime{
+		"second": expr.LimitTimeSecond,
+		"minute": expr.LimitTimeMinute,
+		"hour":   expr.LimitTimeHour,
+		"day":    expr.LimitTimeDay,
+	}
+	nftLogLevelNumbers = map[string]expr.LogLevel{
+		"emerg":   expr.LogLevelEmerg,
+		"alert":   expr.LogLevelAlert,
+		"crit":    expr.LogLevelCrit,
+		"error":   expr.LogLevelErr,
+		"warning": expr.LogLevelWarning,
+		"notice":  expr.LogLevelNotice,
+		"info":    expr.LogLevelInfo,
+		"debug":   expr.LogLevelDebug,
+	}
+)
+
+const (
+	// ICMP and ICMPv6 port unreachable codes
+	nftICMPPortUnreachable   = 3
+	nftICMPv6PortUnreachable = 4
+)
+
+// nftBuilder holds state shared by rules of a single netlink transaction
+type nftBuilder struct {
+	conn   *nftables.Conn
+	table  *nftables.Table
+	family string
+	// Meters by name, rules using the same name share the state
+	meters map[string]*nftables.Set
+}
+
+// load appends expressions loading payload into register 1
+func (b *nftBuilder) load(exprs []expr.Any, base expr.PayloadBase, offset, length uint32) []expr.Any {
+	return append(exprs, &expr.Payload{DestRegister: 1, Base: base, Offset: offset, Len: length})
+}
+
+// loadAddress appends expressions loading source or destination address masked to given prefix length into register 1
+func (b *nftBuilder) loadAddress(exprs []expr.Any, destination bool, bits int) []expr.Any {
+	offset, length := uint32(12), uint32(4)
+	if b.family == "ip6" {
+		offset, length = 8, 16
+	}
+	if destination {
+		offset += length
+	}
+
+	exprs = b.load(exprs, expr.PayloadBaseNetworkHeader, offset, length)
+	if bits < nftFullMask(b.family) {
+		exprs = append(exprs, &expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            length,
+			Mask:           []byte(nftMask(b.family, bits)),
+			Xor:            make([]byte, length),
+		})
+	}
+	return exprs
+}
+
+func nftCmpOp(negate bool) expr.CmpOp {
+	if negate {
+		return expr.CmpOpNeq
+	}
+	return expr.CmpOpEq
+}
+
+func (m *nftAddressMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	exprs = b.loadAddress(exprs, m.destination, m.prefix.Bits())
+	return append(exprs, &expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: m.prefix.Addr().AsSlice()}), nil
+}
+
+func (m *nftProtocolMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	number, ok := nftProtocolNumbers[strings.ToLower(m.protocol)]
+	if !ok {
+		var err error
+		if number, err = parseNFTUint8(m.protocol); err != nil {
+			return nil, fmt.Errorf("unsupported protocol '%s'", m.protocol)
+		}
+	}
+
+	exprs = append(exprs, &expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1})
+	return append(exprs, &expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: []byte{number}}), nil
+}
+
+func (m *nftPortMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	offset := uint32(2)
+	if m.source {
+		offset = 0
+	}
+	exprs = b.load(exprs, expr.PayloadBaseTransportHeader, offset, 2)
+
+	if !m.set {
+		r := m.ranges[0]
+		if r[0] == r[1] {
+			return append(exprs, &expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: binaryutil.BigEndian.PutUint16(r[0])}), nil
+		}
+		return append(exprs, &expr.Range{
+			Op:       nftCmpOp(m.negate),
+			Register: 1,
+			FromData: binaryutil.BigEndian.PutUint16(r[0]),
+			ToData:   binaryutil.BigEndian.PutUint16(r[1]),
+		}), nil
+	}
+
+	// Interval set elements mark interval starts, interval ends are exclusive
+	var elements []nftables.SetElement
+	for _, r := range m.ranges {
+		elements = append(elements, nftables.SetElement{Key: binaryutil.BigEndian.PutUint16(r[0])})
+		if r[1] < 0xffff {
+			elements = append(elements, nftables.SetElement{Key: binaryutil.BigEndian.PutUint16(r[1] + 1), IntervalEnd: true})
+		}
+	}
+
+	set := &nftables.Set{
+		Table:     b.table,
+		Anonymous: true,
+		Constant:  true,
+		Interval:  true,
+		KeyType:   nftables.TypeInetService,
+	}
+	if err := b.conn.AddSet(set, elements); err != nil {
+		return nil, err
+	}
+	return append(exprs, &expr.Lookup{SourceRegister: 1, SetName: set.Name, SetID: set.ID, Invert: m.negate}), nil
+}
+
+func (m *nftInterfaceMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	key := expr.MetaKeyIIFNAME
+	if m.output {
+		key = expr.MetaKeyOIFNAME
+	}
+
+	// Interface names are compared as NUL padded IFNAMSIZ sized buffers
+	name := make([]byte, unix.IFNAMSIZ)
+	copy(name, m.name)
+	return append(exprs,
+		&expr.Meta{Key: key, Register: 1},
+		&expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: name},
+	), nil
+}
+
+func (m *nftTCPFlagsMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	exprs = b.load(exprs, expr.PayloadBaseTransportHeader, 13, 1)
+	return append(exprs,
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 1, Mask: []byte{m.mask}, Xor: []byte{0}},
+		&expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: []byte{m.comp}},
+	), nil
+}
+
+func (m *nftTCPOptionMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	// Presence is loaded as a boolean
+	present := []byte{1}
+	if m.negate {
+		present = []byte{0}
+	}
+	return append(exprs,
+		&expr.Exthdr{
+			Op:           expr.ExthdrOpTcpopt,
+			Type:         m.kind,
+			Len:          1,
+			Flags:        unix.NFT_EXTHDR_F_PRESENT,
+			DestRegister: 1,
+		},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: present},
+	), nil
+}
+
+func (m *nftICMPMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	exprs = b.load(exprs, expr.PayloadBaseTransportHeader, 0, 1)
+	exprs = append(exprs, &expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: []byte{m.typ}})
+	if m.hasCode {
+		exprs = b.load(exprs, expr.PayloadBaseTransportHeader, 1, 1)
+		exprs = append(exprs, &expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{m.code}})
+	}
+	return exprs, nil
+}
+
+func (m *nftMACMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	// Link layer header is ethernet only on ethernet interfaces
+	exprs = append(exprs,
+		&expr.Meta{Key: expr.MetaKeyIIFTYPE, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.NativeEndian.PutUint16(unix.ARPHRD_ETHER)},
+	)
+	exprs = b.load(exprs, expr.PayloadBaseLLHeader, 6, 6)
+	return append(exprs, &expr.Cmp{Op: nftCmpOp(m.negate), Register: 1, Data: []byte(m.mac)}), nil
+}
+
+func (m *nftStateMatch) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	var bits uint32
+	for _, state := range m.states {
+		bit, ok := nftStateBits[state]
+		if !ok {
+			return nil, fmt.Errorf("unsupported connection state '%s'", state)
+		}
+		bits |= bit
+	}
+
+	// Any of the states matches, thus none of them must match when negated
+	op := expr.CmpOpNeq
+	if m.negate {
+		op = expr.CmpOpEq
+	}
+
+	return append(exprs,
+		&expr.Ct{Key: expr.CtKeySTATE, Register: 1},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            4,
+			Mask:           binaryutil.NativeEndian.PutUint32(bits),
+			Xor:            binaryutil.NativeEndian.PutUint32(0),
+		},
+		&expr.Cmp{Op: op, Register: 1, Data: binaryutil.NativeEndian.PutUint32(0)},
+	), nil
+}
+
+func (l *nftLimit) expr() *expr.Limit {
+	return &expr.Limit{Type: expr.LimitTypePkts, Rate: l.rate, Unit: nftLimitUnits[l.unit], Burst: l.burst}
+}
+
+func (l *nftLimit) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	return append(exprs, l.expr()), nil
+}
+
+func (m *nftMeter) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	set, ok := b.meters[m.name]
+	if !ok {
+		set = &nftables.Set{
+			Table:   b.table,
+			Name:    m.name,
+			Dynamic: true,
+			KeyType: nftables.TypeIPAddr,
+		}
+		if b.family == "ip6" {
+			set.KeyType = nftables.TypeIP6Addr
+		}
+		if err := b.conn.AddSet(set, nil); err != nil {
+			return nil, err
+		}
+		b.meters[m.name] = set
+	}
+
+	var statement expr.Any = &expr.Connlimit{Count: m.connOver, Flags: expr.NFT_CONNLIMIT_F_INV}
+	if m.limit != nil {
+		statement = m.limit.expr()
+	}
+
+	exprs = b.loadAddress(exprs, false, m.mask)
+	return append(exprs, &expr.Dynset{
+		SrcRegKey: 1,
+		SetName:   set.Name,
+		SetID:     set.ID,
+		Operation: unix.NFT_DYNSET_OP_UPDATE,
+		Exprs:     []expr.Any{statement},
+	}), nil
+}
+
+func (v *nftVerdict) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	verdict := &expr.Verdict{Chain: v.chain}
+	switch v.kind {
+	case "accept":
+		verdict.Kind = expr.VerdictAccept
+	case "drop":
+		verdict.Kind = expr.VerdictDrop
+	case "return":
+		verdict.Kind = expr.VerdictReturn
+	case "jump":
+		verdict.Kind = expr.VerdictJump
+	case "goto":
+		verdict.Kind = expr.VerdictGoto
+	}
+	return append(exprs, verdict), nil
+}
+
+func (r *nftReject) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	reject := &expr.Reject{Type: unix.NFT_REJECT_ICMP_UNREACH, Code: nftICMPPortUnreachable}
+	if r.icmpv6 {
+		reject.Code = nftICMPv6PortUnreachable
+	}
+	return append(exprs, reject), nil
+}
+
+func (l *nftLog) exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error) {
+	log := &expr.Log{Key: 1 << unix.NFTA_LOG_PREFIX, Data: []byte(l.prefix)}
+	if l.nflog {
+		log.Key |= 1 << unix.NFTA_LOG_GROUP
+		log.Group = l.group
+		return append(exprs, log), nil
+	}
+
+	level, ok := nftLogLevelNumbers[l.level]
+	if !ok {
+		n, err := parseNFTUint8(l.level)
+		if err != nil || n > uint8(expr.LogLevelDebug) {
+			return nil, fmt.Errorf("unsupported log level '%s'", l.level)
+		}
+		level = expr.LogLevel(n)
+	}
+	log.Key |= 1 << unix.NFTA_LOG_LEVEL
+	log.Level = level
+	return append(exprs, log), nil
+}
@@ -0,0 +1,222 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/userdata"
+	"golang.org/x/sys/unix"
+
+	"github.com/ZentriaMC/swdfw/internal/chain"
+	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+// newNetNS creates a network namespace, closed when the test finishes
+func newNetNS(t *testing.T) int {
+	t.Helper()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	threadNS := fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid())
+	orig, err := unix.Open(threadNS, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("failed to open network namespace: %s", err)
+	}
+	defer unix.Close(orig)
+
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		t.Skipf("unable to create network namespace: %s", err)
+	}
+
+	ns, err := unix.Open(threadNS, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if serr := unix.Setns(orig, unix.CLONE_NEWNET); serr != nil {
+		// Thread stays locked, thus runtime terminates it
+		t.Fatalf("failed to restore network namespace: %s", serr)
+	}
+	if err != nil {
+		t.Fatalf("failed to open network namespace: %s", err)
+	}
+
+	t.Cleanup(func() { _ = unix.Close(ns) })
+	return ns
+}
+
+func TestNetlinkExecutor(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating network namespace requires root")
+	}
+
+	ctx := context.Background()
+	ns := newNetNS(t)
+
+	conn, err := nftables.New(nftables.WithNetNSFd(ns))
+	if err != nil {
+		t.Fatalf("failed to create netlink connection: %s", err)
+	}
+	if _, err := conn.ListTables(); err != nil {
+		t.Skipf("nf_tables is not available: %s", err)
+	}
+
+	rules := []rule.Rule{
+		{ID: "ssh", Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.0/24", "2001:db8::/32"), Ports: rule.MustParsePorts("ssh"), Action: "allow",
+			RateLimit: &rule.RateLimit{Rate: "10/s", PerSource: true, SourceMask: 24}},
+		{ID: "web-limit", Protocol: "tcp", Ports: rule.MustParsePorts("80", "443", "8000-8999"), Action: "block",
+			ConnLimit: &rule.ConnLimit{Above: 100}},
+		{ID: "web", Protocol: "tcp", Ports: rule.MustParsePorts("80", "443", "8000-8999"), Action: "allow"},
+		{ID: "ping", Protocol: "icmp", Action: "allow", Flags: []string{"icmp:echo-request", "state:new"}},
+		{ID: "mac", Protocol: "udp", Source: rule.MustParseCIDR("0.0.0.0/0"), SourceMAC: "!02:00:00:00:00:01", Action: "block"},
+		{ID: "mptcp", Protocol: "tcp", SourceInterface: "lo", SourceStartPort: 1024, Port: 22, Action: "allow", Flags: []string{"tcp:syn", "tcpopt:30"}},
+		{ID: "block", Protocol: "all", Source: rule.MustParseCIDR("192.0.2.0/24"), Action: "block", Comment: "'quoted' comment"},
+	}
+
+	// Ruleset generator records the expected state
+	rg := chain.NewRulesetGenerator()
+	ne := chain.NewNetlinkExecutor(nftables.WithNetNSFd(ns))
+	newChainManager := func(ne *chain.NetlinkExecutor) chain.ChainManager {
+		executor := func(ctx context.Context, command ...string) (err error) {
+			if err = ne.Executor()(ctx, command...); err == nil {
+				err = rg.Executor()(ctx, command...)
+			}
+			return
+		}
+
+		c, err := chain.NewChainManager(
+			chain.WithCustomExecutor(executor),
+			chain.WithLogBlocked(&rule.Log{Prefix: "blocked "}),
+		)
+		if err != nil {
+			t.Fatalf("failed to initialize chainmanager: %s", err)
+		}
+		return c
+	}
+	c := newChainManager(ne)
+
+	for _, name := range []string{"SWDFW-INPUT", "SWDFW-DEFAULT"} {
+		if err := c.InstallBaseChain(ctx, name, "INPUT"); err != nil {
+			t.Fatalf("failed to install base chain: %s", err)
+		}
+	}
+
+	// Failing command is reported like iptables reports it and does not change rules
+	var cmdErr *cmdchain.ChainExecError
+	err = ne.Executor()(ctx, "iptables", "-t", "filter", "-X", "SWDFW-INPUT")
+	if !errors.As(err, &cmdErr) || cmdErr.ExitStatus() != 1 {
+		t.Fatalf("expected chain in use error, got: %v", err)
+	}
+
+	// Cancelled command does not change rules
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := ne.Executor()(cancelled, "iptables", "-t", "filter", "-N", "cancelled"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled command to fail, got: %v", err)
+	}
+
+	ipv4Table := &nftables.Table{Name: "swdfw_filter", Family: nftables.TableFamilyIPv4}
+	inputHandles := func() (handles []uint64) {
+		nftRules, err := conn.GetRules(ipv4Table, &nftables.Chain{Name: "INPUT", Table: ipv4Table})
+		if err != nil {
+			t.Fatalf("failed to get rules: %s", err)
+		}
+		for _, r := range nftRules {
+			handles = append(handles, r.Handle)
+		}
+		return
+	}
+	installed := inputHandles()
+
+	opts := []chain.ChainOpt{chain.WithConntrackPrelude(true), chain.WithICMPv6NeighborDiscovery(true)}
+	for _, chainRules := range [][]rule.Rule{rules[:1], rules} {
+		if err := c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "SWDFW-DEFAULT", chainRules, opts...); err != nil {
+			t.Fatalf("failed to configure chain: %s", err)
+		}
+	}
+
+	// Rules not affected by changes are kept as they are
+	if handles := inputHandles(); fmt.Sprint(handles) != fmt.Sprint(installed) {
+		t.Errorf("expected rules of INPUT chain to be kept, handles changed from %v to %v", installed, handles)
+	}
+
+	// Another executor continues with rules found in the kernel
+	c = newChainManager(chain.NewNetlinkExecutor(nftables.WithNetNSFd(ns)))
+	for _, name := range []string{"SWDFW-INPUT", "SWDFW-DEFAULT"} {
+		if err := c.InstallBaseChain(ctx, name, "INPUT"); err != nil {
+			t.Fatalf("failed to install base chain after reload: %s", err)
+		}
+	}
+	if handles := inputHandles(); fmt.Sprint(handles) != fmt.Sprint(installed) {
+		t.Errorf("expected installed base chains to be found after reload, handles changed from %v to %v", installed, handles)
+	}
+	if err := c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "SWDFW-DEFAULT", rules[1:], opts...); err != nil {
+		t.Fatalf("failed to configure chain after reload: %s", err)
+	}
+
+	for _, proto := range []rule.Protocol{rule.ProtocolIPv4, rule.ProtocolIPv6} {
+		table := &nftables.Table{Name: "swdfw_filter", Family: nftables.TableFamilyIPv4}
+		if proto == rule.ProtocolIPv6 {
+			table.Family = nftables.TableFamilyIPv6
+		}
+
+		expected := map[string]int{}
+		for _, line := range strings.Split(rg.Restore(proto), "\n") {
+			if strings.HasPrefix(line, ":") {
+				expected[strings.Fields(line[1:])[0]] += 0
+			} else if strings.HasPrefix(line, "-A ") {
+				expected[strings.Fields(line)[1]]++
+			}
+		}
+
+		chains, err := conn.ListChainsOfTableFamily(table.Family)
+		if err != nil {
+			t.Fatalf("failed to list chains: %s", err)
+		}
+
+		actual := map[string]int{}
+		var comments []string
+		for _, ch := range chains {
+			if ch.Table.Name != table.Name {
+				continue
+			}
+
+			nftRules, err := conn.GetRules(table, ch)
+			if err != nil {
+				t.Fatalf("failed to get rules of chain '%s': %s", ch.Name, err)
+			}
+			actual[ch.Name] = len(nftRules)
+			for _, r := range nftRules {
+				if comment, ok := userdata.GetString(r.UserData, userdata.TypeComment); ok {
+					comments = append(comments, comment)
+				}
+			}
+		}
+
+		if fmt.Sprint(actual) != fmt.Sprint(expected) {
+			t.Errorf("%v: expected chains and rule counts %v, got %v", proto, expected, actual)
+		}
+		if proto == rule.ProtocolIPv4 && !strings.Contains(strings.Join(comments, "\n"), "'quoted' comment") {
+			t.Errorf("expected rule comment to be preserved, got %q", comments)
+		}
+	}
+
+	// Rules were installed in a different namespace
+	hostConn, err := nftables.New()
+	if err != nil {
+		t.Fatalf("failed to create netlink connection: %s", err)
+	}
+	hostTables, err := hostConn.ListTables()
+	if err != nil {
+		t.Fatalf("failed to list tables: %s", err)
+	}
+	for _, table := range hostTables {
+		if table.Name == "swdfw_filter" {
+			t.Errorf("table was created outside of test network namespace")
+		}
+	}
+}
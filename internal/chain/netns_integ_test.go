@@ -0,0 +1,248 @@
+package chain_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/chain"
+	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+// skipNetNS skips the test, unless namespace tests are required using SWDFW_REQUIRE_NETNS=1
+func skipNetNS(t *testing.T, format string, args ...interface{}) {
+	t.Helper()
+
+	if os.Getenv("SWDFW_REQUIRE_NETNS") == "1" {
+		t.Fatalf(format, args...)
+	}
+	t.Skipf(format, args...)
+}
+
+// userNetNS is a user and network namespace kept alive by a process sleeping in it
+type userNetNS struct {
+	holder   *exec.Cmd
+	lockFile string
+}
+
+// newUserNetNS creates a user namespace mapping the current user to root and a network namespace owned by it,
+// destroyed when the test finishes. The test is skipped when namespaces cannot be created, see skipNetNS.
+func newUserNetNS(t *testing.T) *userNetNS {
+	t.Helper()
+
+	if _, err := exec.LookPath("nsenter"); err != nil {
+		skipNetNS(t, "nsenter is not available")
+	}
+
+	holder := exec.Command("sleep", "infinity")
+	holder.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: syscall.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: syscall.Getgid(), Size: 1}},
+	}
+	// Namespaces and id mappings are in place once the process has started
+	if err := holder.Start(); err != nil {
+		skipNetNS(t, "unable to create user and network namespace: %s", err)
+	}
+
+	t.Cleanup(func() {
+		_ = holder.Process.Kill()
+		_ = holder.Wait()
+	})
+
+	return &userNetNS{
+		holder: holder,
+		// Host lock file is not writable from user namespace of an unprivileged user
+		lockFile: filepath.Join(t.TempDir(), "xtables.lock"),
+	}
+}
+
+// command returns command line running given command in the namespace
+func (ns *userNetNS) command(command ...string) []string {
+	return append([]string{
+		"nsenter", "--target", strconv.Itoa(ns.holder.Process.Pid), "--user", "--net", "--",
+		"env", "XTABLES_LOCKFILE=" + ns.lockFile,
+	}, command...)
+}
+
+func (ns *userNetNS) Executor() cmdchain.Executor {
+	return func(ctx context.Context, command ...string) (err error) {
+		err = cmdchain.DefaultChainExecutor(ctx, ns.command(command...)...)
+
+		var cmdErr *cmdchain.ChainExecError
+		if errors.As(err, &cmdErr) {
+			cmdErr.Args = command
+		}
+		return
+	}
+}
+
+// output runs command in the namespace, returning its standard output
+func (ns *userNetNS) output(command ...string) (stdout string, err error) {
+	line := ns.command(command...)
+
+	var out, stderr bytes.Buffer
+	cmd := exec.Command(line[0], line[1:]...)
+	cmd.Stdout, cmd.Stderr = &out, &stderr
+	if err = cmd.Run(); err != nil {
+		err = fmt.Errorf("'%s' failed: %w: %s", strings.Join(command, " "), err, stderr.String())
+	}
+	stdout = out.String()
+	return
+}
+
+// modelledExecutor runs commands using executor, recording successful ones in generator
+func modelledExecutor(executor cmdchain.Executor, rg *chain.RulesetGenerator) cmdchain.Executor {
+	model := rg.Executor()
+	return func(ctx context.Context, command ...string) (err error) {
+		if err = executor(ctx, command...); err == nil {
+			err = model(ctx, command...)
+		}
+		return
+	}
+}
+
+// savedRules parses iptables-save output of a single table into rules by chain. Rules are described by their
+// comment and target only, as iptables-save normalizes matches.
+func savedRules(saved string) map[string][]string {
+	chains := map[string][]string{}
+	for _, line := range strings.Split(saved, "\n") {
+		if strings.HasPrefix(line, ":") {
+			chains[strings.Fields(line[1:])[0]] = nil
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+
+		var comment, target string
+		if _, c, ok := strings.Cut(line, "--comment "); ok {
+			if strings.HasPrefix(c, `"`) {
+				c = strings.ReplaceAll(c[1:], `\"`, "\x00")
+				c, _, _ = strings.Cut(c, `"`)
+				comment = strings.ReplaceAll(c, "\x00", `"`)
+			} else {
+				comment, _, _ = strings.Cut(c, " ")
+			}
+		}
+		for i := 2; i < len(fields)-1; i++ {
+			if fields[i] == "-j" || fields[i] == "-g" {
+				target = fields[i] + " " + fields[i+1]
+			}
+		}
+
+		chains[fields[1]] = append(chains[fields[1]], strings.TrimSpace(comment+" "+target))
+	}
+	return chains
+}
+
+// assertSaved compares iptables-save output of filter table with rules recorded by generator
+func assertSaved(t *testing.T, proto rule.Protocol, saved string, rg *chain.RulesetGenerator) {
+	t.Helper()
+
+	expected, actual := savedRules(rg.Restore(proto)), savedRules(saved)
+	if fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Errorf("%v rules do not match, expected:\n%v\ngot:\n%v\niptables-save output:\n%s", proto, expected, actual, saved)
+	}
+}
+
+func TestChainNetNS(t *testing.T) {
+	cases := []struct {
+		name   string
+		paths  [2]string
+		saves  [2]string
+		quirks []chain.Quirk
+	}{
+		{
+			name:  "legacy",
+			paths: [2]string{"iptables-legacy", "ip6tables-legacy"},
+			saves: [2]string{"iptables-legacy-save", "ip6tables-legacy-save"},
+		},
+		{
+			name:   "nft",
+			paths:  [2]string{"iptables-nft", "ip6tables-nft"},
+			saves:  [2]string{"iptables-nft-save", "ip6tables-nft-save"},
+			quirks: []chain.Quirk{chain.QuirkIPTablesBrokenChainCheck},
+		},
+	}
+
+	inputRules := []rule.Rule{
+		{ID: "ssh", Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.1/24"), Port: 22, Action: "allow"},
+		{ID: "high", Protocol: "tcp", StartPort: 1024, EndPort: 4096, Action: "allow"},
+		{ID: "ping", Protocol: "icmp", Action: "allow", Flags: []string{"icmp:echo-request"}},
+		{ID: "block", Protocol: "tcp", Action: "block"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, program := range append(tc.paths[:], tc.saves[:]...) {
+				if _, err := exec.LookPath(program); err != nil {
+					skipNetNS(t, "%s is not available", program)
+				}
+			}
+
+			ctx := context.Background()
+			ns := newUserNetNS(t)
+			for _, program := range tc.paths {
+				if _, err := ns.output(program, "-t", "filter", "-S"); err != nil {
+					skipNetNS(t, "%s does not work in user namespace: %s", program, err)
+				}
+			}
+
+			rg := chain.NewRulesetGenerator()
+			c, err := chain.NewChainManager(
+				chain.WithCustomExecutor(modelledExecutor(ns.Executor(), rg)),
+				chain.WithProtocols(rule.ProtocolIPv4, rule.ProtocolIPv6),
+				chain.WithParallelism(1),
+				chain.VerifyIPTablesPath(false),
+				chain.IPTablesPath(tc.paths[0]),
+				chain.IP6TablesPath(tc.paths[1]),
+				chain.Quirks(tc.quirks...),
+			)
+			if err != nil {
+				t.Fatalf("failed to initialize chainmanager: %s", err)
+			}
+
+			defer func() {
+				if cerr := c.Close(); cerr != nil {
+					t.Logf("failed to close chainmanager: %s", cerr)
+				}
+			}()
+
+			for _, base := range [][2]string{{"SWDFW-INPUT", "INPUT"}, {"SWDFW-OUTPUT", "OUTPUT"}} {
+				if err := c.InstallBaseChain(ctx, base[0], base[1]); err != nil {
+					t.Fatalf("failed to install base chain '%s': %s", base[0], err)
+				}
+			}
+
+			// Second run replaces rules of the first one
+			for _, rules := range [][]rule.Rule{inputRules[:1], inputRules, inputRules} {
+				if err := c.ConfigureChain(ctx, "basicrules-input", "SWDFW-INPUT", "", rules); err != nil {
+					t.Fatalf("failed to replace chain: %s", err)
+				}
+			}
+			if err := c.ConfigureChain(ctx, "basicrules-output", "SWDFW-OUTPUT", "", nil); err != nil {
+				t.Fatalf("failed to replace chain: %s", err)
+			}
+
+			for i, proto := range []rule.Protocol{rule.ProtocolIPv4, rule.ProtocolIPv6} {
+				saved, err := ns.output(tc.saves[i], "-t", "filter")
+				if err != nil {
+					t.Fatalf("failed to get rules: %s", err)
+				}
+				assertSaved(t, proto, saved, rg)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+const defaultRefreshInterval = 5 * time.Minute
+
+// Reconciler keeps a chain configured with rules containing hostnames. Hostnames are re-resolved periodically and
+// chain is reconfigured only when resolved rules change.
+type Reconciler struct {
+	manager     ChainManager
+	resolver    rule.Resolver
+	interval    time.Duration
+	name        string
+	parentChain string
+	jumpTo      string
+	rules       []rule.Rule
+	chainOpts   []ChainOpt
+
+	applied []byte
+}
+
+type ReconcilerOpt func(*Reconciler)
+
+func NewReconciler(manager ChainManager, name, parentChain, jumpTo string, rules []rule.Rule, opts ...ReconcilerOpt) (r *Reconciler) {
+	r = &Reconciler{
+		manager:     manager,
+		resolver:    net.DefaultResolver,
+		interval:    defaultRefreshInterval,
+		name:        name,
+		parentChain: parentChain,
+		jumpTo:      jumpTo,
+		rules:       rules,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return
+}
+
+func WithResolver(resolver rule.Resolver) ReconcilerOpt {
+	return func(r *Reconciler) {
+		r.resolver = resolver
+	}
+}
+
+// WithRefreshInterval sets how often hostnames are re-resolved, non-positive intervals keep the default
+func WithRefreshInterval(interval time.Duration) ReconcilerOpt {
+	return func(r *Reconciler) {
+		if interval > 0 {
+			r.interval = interval
+		}
+	}
+}
+
+// WithReconcilerChainOpts sets options passed to ConfigureChain
+func WithReconcilerChainOpts(opts ...ChainOpt) ReconcilerOpt {
+	return func(r *Reconciler) {
+		r.chainOpts = opts
+	}
+}
+
+// Reconcile resolves rules and reconfigures the chain if resolved rules differ from last applied ones.
+// Resolution errors are returned after applying rules failing closed.
+func (r *Reconciler) Reconcile(ctx context.Context) (changed bool, err error) {
+	resolved, rerr := rule.ResolveRules(ctx, r.resolver, r.rules)
+	if rerr != nil {
+		err = fmt.Errorf("failed to resolve rules: %w", rerr)
+	}
+
+	var state []byte
+	var merr error
+	if state, merr = json.Marshal(resolved); merr != nil {
+		err = multierr.Append(err, merr)
+		return
+	}
+
+	if r.applied != nil && string(state) == string(r.applied) {
+		return
+	}
+
+	if cerr := r.manager.ConfigureChain(ctx, r.name, r.parentChain, r.jumpTo, resolved, r.chainOpts...); cerr != nil {
+		err = multierr.Append(err, cerr)
+		return
+	}
+
+	r.applied = state
+	changed = true
+	return
+}
+
+// Run reconciles the chain right away and then on every refresh interval, until context is cancelled
+func (r *Reconciler) Run(ctx context.Context) (err error) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		changed, rerr := r.Reconcile(ctx)
+		if rerr != nil {
+			zap.L().Warn("failed to reconcile chain", zap.String("chain", r.name), zap.Error(rerr))
+		} else if changed {
+			zap.L().Info("reconfigured chain", zap.String("chain", r.name))
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZentriaMC/swdfw/internal/chain"
+	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+func TestReconcilerHostnames(t *testing.T) {
+	var commands []string
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		commands = append(commands, strings.Join(args, " "))
+		return nil
+	}
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(collectingExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.WithChecks(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	resolver := rule.StaticResolver{
+		"peer.example.com": {netip.MustParseAddr("192.0.2.10")},
+	}
+
+	rules := []rule.Rule{
+		{
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("peer.example.com"),
+			StartPort: 5432,
+			Action:    "allow",
+		},
+	}
+
+	r := chain.NewReconciler(c, "peers", "SWDFW-INPUT", "", rules, chain.WithResolver(resolver))
+	ctx := context.Background()
+
+	appendedRules := func() (appended []string) {
+		for _, command := range commands {
+			if strings.Contains(command, " -A peers:") {
+				appended = append(appended, command)
+			}
+		}
+		commands = nil
+		return
+	}
+
+	if changed, err := r.Reconcile(ctx); err != nil || !changed {
+		t.Fatalf("expected initial reconcile to configure chain (changed=%t, err=%v)", changed, err)
+	}
+
+	if appended := appendedRules(); len(appended) != 1 || !strings.Contains(appended[0], "-s 192.0.2.10/32") {
+		t.Errorf("unexpected rules appended: %v", appended)
+	}
+
+	if changed, err := r.Reconcile(ctx); err != nil || changed {
+		t.Errorf("expected reconcile with unchanged addresses to be no-op (changed=%t, err=%v)", changed, err)
+	}
+
+	if appended := appendedRules(); len(appended) != 0 {
+		t.Errorf("expected no rules to be appended, got %v", appended)
+	}
+
+	resolver["peer.example.com"] = []netip.Addr{netip.MustParseAddr("192.0.2.11")}
+	if changed, err := r.Reconcile(ctx); err != nil || !changed {
+		t.Errorf("expected reconcile with changed addresses to reconfigure chain (changed=%t, err=%v)", changed, err)
+	}
+
+	if appended := appendedRules(); len(appended) != 1 || !strings.Contains(appended[0], "-s 192.0.2.11/32") {
+		t.Errorf("unexpected rules appended: %v", appended)
+	}
+
+	delete(resolver, "peer.example.com")
+	if changed, err := r.Reconcile(ctx); err == nil || !changed {
+		t.Errorf("expected reconcile with failing resolution to fail closed (changed=%t, err=%v)", changed, err)
+	}
+
+	if appended := appendedRules(); len(appended) != 0 {
+		t.Errorf("expected allow rule to be omitted, got %v", appended)
+	}
+}
+
+func TestReconcilerNonPositiveInterval(t *testing.T) {
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(func(ctx context.Context, args ...string) error { return nil }),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.WithChecks(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		r := chain.NewReconciler(c, "peers", "SWDFW-INPUT", "", nil, chain.WithRefreshInterval(interval))
+		if err := r.Run(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected run with interval %s to stop on cancellation, got %v", interval, err)
+		}
+	}
+}
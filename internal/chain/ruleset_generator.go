@@ -0,0 +1,363 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+var (
+	// Built-in chains of supported tables, in iptables-save order
+	iptBuiltinChains = map[string][]string{
+		"filter": {"INPUT", "FORWARD", "OUTPUT"},
+	}
+	iptStandardTargets = map[string]bool{
+		"ACCEPT": true,
+		"DROP":   true,
+		"RETURN": true,
+		"REJECT": true,
+		"LOG":    true,
+		"NFLOG":  true,
+	}
+)
+
+// RulesetGenerator records tables, chains and rules created by iptables commands executed through it, as if they
+// ran on a host without any rules, and renders them as iptables-restore input or nftables script. Commands fail with
+// the same exit status and messages as iptables, thus ChainManager can be used with checks enabled.
+// Commands of different address families do not affect each other, thus they may run in parallel.
+type RulesetGenerator struct {
+	mu     sync.Mutex
+	tables map[rule.Protocol]map[string]*iptTable
+}
+
+type iptTable struct {
+	chains map[string]*iptChain
+}
+
+type iptChain struct {
+	builtin bool
+	rules   [][]string
+}
+
+func NewRulesetGenerator() *RulesetGenerator {
+	return &RulesetGenerator{
+		tables: map[rule.Protocol]map[string]*iptTable{},
+	}
+}
+
+func (g *RulesetGenerator) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tables = map[rule.Protocol]map[string]*iptTable{}
+}
+
+func (g *RulesetGenerator) Executor() cmdchain.Executor {
+	return func(ctx context.Context, command ...string) error {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.execute(command)
+	}
+}
+
+// iptCommand is iptables command line split into parts the generator cares about
+type iptCommand struct {
+	line      []string
+	prog      string
+	proto     rule.Protocol
+	tableName string
+	action    string
+	chainName string
+	args      []string
+}
+
+func parseIPTCommand(command []string) (cmd *iptCommand, err error) {
+	cmd = &iptCommand{line: command, prog: filepath.Base(command[0]), tableName: "filter"}
+	if strings.Contains(cmd.prog, "ip6tables") {
+		cmd.proto = rule.ProtocolIPv6
+	} else if strings.Contains(cmd.prog, "iptables") {
+		cmd.proto = rule.ProtocolIPv4
+	} else {
+		err = fmt.Errorf("unsupported program '%s'", command[0])
+		return
+	}
+
+	args := command[1:]
+	for len(args) > 0 {
+		if args[0] == "--wait" || args[0] == "-w" {
+			args = args[1:]
+			if len(args) > 0 {
+				if _, werr := strconv.Atoi(args[0]); werr == nil {
+					args = args[1:]
+				}
+			}
+		} else if args[0] == "-t" && len(args) > 1 {
+			cmd.tableName, args = args[1], args[2:]
+		} else {
+			break
+		}
+	}
+
+	if len(args) < 2 {
+		err = cmd.fail(2, fmt.Sprintf("unsupported command %v\n", args))
+		return
+	}
+	cmd.action, cmd.chainName, cmd.args = args[0], args[1], args[2:]
+	return
+}
+
+func (cmd *iptCommand) fail(status int, msg string) error {
+	return &cmdchain.ChainExecError{Args: cmd.line, Stderr_: fmt.Sprintf("%s: %s", cmd.prog, msg), Status: status}
+}
+
+// mutating returns whether command changes rules
+func (cmd *iptCommand) mutating() bool {
+	return cmd.action != "-S" && cmd.action != "-C"
+}
+
+func (g *RulesetGenerator) execute(command []string) (err error) {
+	var cmd *iptCommand
+	if cmd, err = parseIPTCommand(command); err != nil {
+		return
+	}
+	return g.apply(cmd)
+}
+
+func (g *RulesetGenerator) apply(cmd *iptCommand) (err error) {
+	fail, prog, tableName, chainName, args := cmd.fail, cmd.prog, cmd.tableName, cmd.chainName, cmd.args
+
+	table, ok := g.table(cmd.proto, tableName)
+	if !ok {
+		return fail(3, fmt.Sprintf("can't initialize %s table `%s': Table does not exist\n", prog, tableName))
+	}
+
+	action := cmd.action
+	if action == "-N" {
+		if _, exists := table.chains[chainName]; exists {
+			return fail(1, msgIPTChainExist)
+		}
+		table.chains[chainName] = &iptChain{}
+		return
+	}
+
+	chain, ok := table.chains[chainName]
+	if !ok {
+		return fail(1, msgIPTNoChainExist)
+	}
+
+	switch action {
+	case "-S":
+		// Rule number is not checked, same as iptables
+	case "-C":
+		if chain.find(args) < 0 {
+			return fail(1, msgIPTNoRuleExist)
+		}
+	case "-A", "-I":
+		position := len(chain.rules)
+		if action == "-I" {
+			position = 0
+			if len(args) > 0 {
+				if n, nerr := strconv.Atoi(args[0]); nerr == nil {
+					if n < 1 || n > len(chain.rules)+1 {
+						return fail(1, msgIPTIndexTooBig)
+					}
+					position, args = n-1, args[1:]
+				}
+			}
+		}
+
+		if target := ruleTarget(args); target != "" && !iptStandardTargets[target] {
+			if _, exists := table.chains[target]; !exists {
+				return fail(2, fmt.Sprintf("Couldn't load target `%s':No such file or directory\n", target))
+			}
+		}
+
+		chain.rules = append(chain.rules[:position], append([][]string{append([]string{}, args...)}, chain.rules[position:]...)...)
+	case "-D":
+		position := chain.find(args)
+		if len(args) == 1 {
+			if n, nerr := strconv.Atoi(args[0]); nerr == nil && n >= 1 && n <= len(chain.rules) {
+				position = n - 1
+			}
+		}
+		if position < 0 {
+			return fail(1, msgIPTNoRuleExist)
+		}
+		chain.rules = append(chain.rules[:position], chain.rules[position+1:]...)
+	case "-F":
+		chain.rules = nil
+	case "-X":
+		if chain.builtin {
+			return fail(2, msgIPTBuiltinChain)
+		}
+		if table.referenced(chainName) {
+			return fail(1, msgIPTChainReferenced)
+		}
+		if len(chain.rules) > 0 {
+			return fail(1, msgIPTChainNotEmpty)
+		}
+		delete(table.chains, chainName)
+	case "-E":
+		if len(args) != 1 {
+			return fail(2, "-E requires old-chain-name and new-chain-name\n")
+		}
+		if _, exists := table.chains[args[0]]; exists {
+			return fail(1, msgIPTRenameExist)
+		}
+		table.rename(chainName, args[0])
+	default:
+		return fail(2, fmt.Sprintf("unsupported action '%s'\n", action))
+	}
+	return
+}
+
+func (g *RulesetGenerator) table(proto rule.Protocol, name string) (table *iptTable, ok bool) {
+	builtins, ok := iptBuiltinChains[name]
+	if !ok {
+		return
+	}
+
+	if g.tables[proto] == nil {
+		g.tables[proto] = map[string]*iptTable{}
+	}
+
+	if table = g.tables[proto][name]; table == nil {
+		table = &iptTable{chains: map[string]*iptChain{}}
+		for _, builtin := range builtins {
+			table.chains[builtin] = &iptChain{builtin: true}
+		}
+		g.tables[proto][name] = table
+	}
+	return
+}
+
+// tableNames returns names of tables used for given protocol in a stable order
+func (g *RulesetGenerator) tableNames(proto rule.Protocol) (names []string) {
+	for name := range g.tables[proto] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// ruleTarget returns jump or goto target of a rulespec
+func ruleTarget(rulespec []string) string {
+	for i := 0; i < len(rulespec)-1; i++ {
+		if rulespec[i] == "-j" || rulespec[i] == "-g" {
+			return rulespec[i+1]
+		}
+	}
+	return ""
+}
+
+func (c *iptChain) find(rulespec []string) int {
+	for i, r := range c.rules {
+		if sameRulespec(r, rulespec) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *iptTable) referenced(chainName string) bool {
+	for _, chain := range t.chains {
+		for _, r := range chain.rules {
+			if ruleTarget(r) == chainName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rename renames a chain, rules referring to it follow like they do in the kernel
+func (t *iptTable) rename(oldName, newName string) {
+	t.chains[newName] = t.chains[oldName]
+	delete(t.chains, oldName)
+
+	for _, chain := range t.chains {
+		for _, r := range chain.rules {
+			for i := 0; i < len(r)-1; i++ {
+				if (r[i] == "-j" || r[i] == "-g") && r[i+1] == oldName {
+					r[i+1] = newName
+				}
+			}
+		}
+	}
+}
+
+// chainNames returns built-in chains followed by user defined chains sorted by name, like iptables-save does
+func (t *iptTable) chainNames(tableName string) (names []string) {
+	var user []string
+	for name, chain := range t.chains {
+		if !chain.builtin {
+			user = append(user, name)
+		}
+	}
+	sort.Strings(user)
+	return append(append(names, iptBuiltinChains[tableName]...), user...)
+}
+
+// Restore renders rules of given protocol as iptables-restore (or ip6tables-restore) input
+func (g *RulesetGenerator) Restore(proto rule.Protocol) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var sb strings.Builder
+	for _, tableName := range g.tableNames(proto) {
+		table := g.tables[proto][tableName]
+		chainNames := table.chainNames(tableName)
+
+		fmt.Fprintf(&sb, "*%s\n", tableName)
+		for _, chainName := range chainNames {
+			policy := "-"
+			if table.chains[chainName].builtin {
+				policy = "ACCEPT"
+			}
+			fmt.Fprintf(&sb, ":%s %s [0:0]\n", chainName, policy)
+		}
+
+		for _, chainName := range chainNames {
+			for _, r := range table.chains[chainName].rules {
+				fmt.Fprintf(&sb, "-A %s", restoreQuote(chainName))
+				for _, arg := range r {
+					sb.WriteString(" " + restoreQuote(arg))
+				}
+				sb.WriteString("\n")
+			}
+		}
+		sb.WriteString("COMMIT\n")
+	}
+	return sb.String()
+}
+
+// restoreQuote quotes argument for iptables-restore, which splits arguments on whitespace outside double quotes
+func restoreQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\#") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// clone returns a deep copy of the table
+func (t *iptTable) clone() *iptTable {
+	if t == nil {
+		return nil
+	}
+
+	c := &iptTable{chains: map[string]*iptChain{}}
+	for name, chain := range t.chains {
+		rules := make([][]string, 0, len(chain.rules))
+		for _, r := range chain.rules {
+			rules = append(rules, append([]string{}, r...))
+		}
+		c.chains[name] = &iptChain{builtin: chain.builtin, rules: rules}
+	}
+	return c
+}
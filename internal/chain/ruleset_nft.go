@@ -0,0 +1,119 @@
+package chain
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+var nftIdentifierPattern = regexp.MustCompile(`^[a-zA-Z_.][a-zA-Z0-9/\\_.\-]*$`)
+
+// NFT renders rules of every protocol as nftables script. Each iptables table becomes 'swdfw_<table>' nftables
+// table of the same family, replaced as a whole when the script is loaded using `nft -f`.
+func (g *RulesetGenerator) NFT() (script string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("#!/usr/sbin/nft -f\n")
+
+	for _, proto := range []rule.Protocol{rule.ProtocolIPv4, rule.ProtocolIPv6} {
+		family := nftFamily(proto)
+		for _, tableName := range g.tableNames(proto) {
+			table := g.tables[proto][tableName]
+			chainNames := table.chainNames(tableName)
+			nftTable := fmt.Sprintf("%s %s", family, nftTableName(tableName))
+
+			// Declaring the table first makes deleting it work on the first load as well
+			fmt.Fprintf(&sb, "\ntable %s\ndelete table %s\ntable %s {\n", nftTable, nftTable, nftTable)
+			for _, chainName := range chainNames {
+				if !nftIdentifierPattern.MatchString(chainName) {
+					err = fmt.Errorf("chain name '%s' is not valid in nftables", chainName)
+					return
+				}
+
+				fmt.Fprintf(&sb, "\tchain %s {\n", chainName)
+				if table.chains[chainName].builtin {
+					fmt.Fprintf(&sb, "\t\ttype %s hook %s priority %s; policy accept;\n", tableName, strings.ToLower(chainName), tableName)
+				}
+				sb.WriteString("\t}\n")
+			}
+			sb.WriteString("}\n")
+
+			for _, chainName := range chainNames {
+				for _, r := range table.chains[chainName].rules {
+					var statement string
+					if statement, err = nftStatement(family, r); err != nil {
+						err = fmt.Errorf("failed to translate rule %v in chain '%s': %w", r, chainName, err)
+						return
+					}
+					fmt.Fprintf(&sb, "add rule %s %s %s\n", nftTable, chainName, statement)
+				}
+			}
+		}
+	}
+
+	script = sb.String()
+	return
+}
+
+func nftFamily(proto rule.Protocol) string {
+	if proto == rule.ProtocolIPv6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+func nftTableName(tableName string) string {
+	return "swdfw_" + tableName
+}
+
+// nftStatement translates iptables rulespec generated by ChainManager into nftables rule statement
+func nftStatement(family string, rulespec []string) (statement string, err error) {
+	var parsed *nftRule
+	if parsed, err = parseNFTRule(family, rulespec); err != nil {
+		return
+	}
+
+	var parts []string
+	for _, component := range parsed.components {
+		parts = append(parts, component.text(family))
+	}
+
+	statement = strings.Join(parts, " ")
+	if strings.Contains(parsed.comment, `"`) {
+		err = fmt.Errorf("comment '%s' cannot contain double quotes", parsed.comment)
+		return
+	}
+
+	if parsed.comment != "" {
+		statement += fmt.Sprintf(" comment \"%s\"", parsed.comment)
+	}
+	return
+}
+
+// nftMeterName derives a stable meter name for a rule without one
+func nftMeterName(rulespec []string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join(rulespec, "\x00")))
+	return fmt.Sprintf("swdfw%08x", h.Sum32())
+}
+
+// nftMask returns address mask of given prefix length for family
+func nftMask(family string, bits int) net.IP {
+	if family == "ip6" {
+		return net.IP(net.CIDRMask(bits, 128))
+	}
+	return net.IP(net.CIDRMask(bits, 32))
+}
+
+func nftFullMask(family string) int {
+	if family == "ip6" {
+		return 128
+	}
+	return 32
+}
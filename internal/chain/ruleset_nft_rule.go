@@ -0,0 +1,560 @@
+package chain
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/google/nftables/expr"
+)
+
+var (
+	nftLogLevels = map[string]string{
+		"error":   "err",
+		"warning": "warn",
+	}
+	nftRejectTypes = map[string]string{
+		"icmp-port-unreachable":  "icmp type port-unreachable",
+		"icmp6-port-unreachable": "icmpv6 type port-unreachable",
+	}
+	nftTCPFlagBits = map[string]uint8{
+		"FIN": 0x01,
+		"SYN": 0x02,
+		"RST": 0x04,
+		"PSH": 0x08,
+		"ACK": 0x10,
+		"URG": 0x20,
+	}
+	// nftTCPFlagOrder lists flags in the order nftables prints them
+	nftTCPFlagOrder = []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG"}
+	nftRateUnits    = map[string]bool{
+		"second": true,
+		"minute": true,
+		"hour":   true,
+		"day":    true,
+	}
+)
+
+// nftRule is iptables rulespec generated by ChainManager parsed into nftables matches and statements, which
+// can be rendered as nftables script or netlink expressions
+type nftRule struct {
+	components []nftComponent
+	comment    string
+}
+
+type nftComponent interface {
+	text(family string) string
+	// exprs appends netlink expressions of the component
+	exprs(b *nftBuilder, exprs []expr.Any) ([]expr.Any, error)
+}
+
+type nftAddressMatch struct {
+	negate      bool
+	destination bool
+	prefix      netip.Prefix
+}
+
+type nftProtocolMatch struct {
+	negate   bool
+	protocol string
+}
+
+type nftPortMatch struct {
+	negate   bool
+	protocol string
+	source   bool
+	set      bool
+	ranges   [][2]uint16
+}
+
+type nftInterfaceMatch struct {
+	negate bool
+	output bool
+	name   string
+}
+
+// nftTCPFlagsMatch requires flags in mask to be set as in comp
+type nftTCPFlagsMatch struct {
+	negate bool
+	mask   uint8
+	comp   uint8
+}
+
+type nftTCPOptionMatch struct {
+	negate bool
+	kind   uint8
+}
+
+type nftICMPMatch struct {
+	negate  bool
+	v6      bool
+	typ     uint8
+	code    uint8
+	hasCode bool
+}
+
+type nftMACMatch struct {
+	negate bool
+	mac    net.HardwareAddr
+}
+
+type nftStateMatch struct {
+	negate bool
+	states []string
+}
+
+type nftLimit struct {
+	rate  uint64
+	unit  string
+	burst uint32
+}
+
+// nftMeter applies limit or connection count per (masked) source address
+type nftMeter struct {
+	name     string
+	mask     int
+	limit    *nftLimit
+	connOver uint32
+}
+
+type nftVerdict struct {
+	kind  string
+	chain string
+}
+
+type nftReject struct {
+	icmpv6 bool
+}
+
+type nftLog struct {
+	prefix string
+	level  string
+	group  uint16
+	nflog  bool
+}
+
+// parseNFTRule parses rulespec generated by ChainManager, rejecting options it cannot translate
+func parseNFTRule(family string, rulespec []string) (parsed *nftRule, err error) {
+	parsed = &nftRule{}
+	negate := false
+	protocol := ""
+
+	value := func(i *int) string {
+		*i++
+		if *i < len(rulespec) {
+			return rulespec[*i]
+		}
+		if err == nil {
+			err = fmt.Errorf("option '%s' requires a value", rulespec[*i-1])
+		}
+		return ""
+	}
+	// options reads following options of the current match or target, flag options having an empty value
+	options := func(i *int, flags map[string]bool) (opts map[string]string) {
+		opts = map[string]string{}
+		for *i+1 < len(rulespec) {
+			name := rulespec[*i+1]
+			hasValue, known := flags[name]
+			if !known {
+				break
+			}
+			*i++
+			if hasValue {
+				opts[name] = value(i)
+			} else {
+				opts[name] = ""
+			}
+		}
+		return
+	}
+	negated := func() bool {
+		n := negate
+		negate = false
+		return n
+	}
+	add := func(component nftComponent, cerr error) {
+		if cerr != nil && err == nil {
+			err = cerr
+		}
+		parsed.components = append(parsed.components, component)
+	}
+
+	for i := 0; i < len(rulespec) && err == nil; i++ {
+		switch opt := rulespec[i]; opt {
+		case "!":
+			negate = true
+		case "-m":
+			// Matches are translated based on their options
+			_ = value(&i)
+		case "-s", "-d":
+			m := &nftAddressMatch{negate: negated(), destination: opt == "-d"}
+			m.prefix, err = parseNFTPrefix(value(&i))
+			add(m, nil)
+		case "-p":
+			protocol = value(&i)
+			if n := negated(); n || protocol != "all" {
+				add(&nftProtocolMatch{negate: n, protocol: protocol}, nil)
+			}
+		case "-i", "-o":
+			add(&nftInterfaceMatch{negate: negated(), output: opt == "-o", name: value(&i)}, nil)
+		case "--dport", "--dports", "--sport", "--sports":
+			m := &nftPortMatch{
+				negate:   negated(),
+				protocol: protocol,
+				source:   strings.HasPrefix(opt, "--s"),
+				set:      strings.HasSuffix(opt, "s"),
+			}
+			for _, port := range strings.Split(value(&i), ",") {
+				var r [2]uint16
+				r, err = parseNFTPortRange(port)
+				m.ranges = append(m.ranges, r)
+			}
+			add(m, nil)
+		case "--icmp-type", "--icmpv6-type":
+			n := negated()
+			typ, code, hasCode := strings.Cut(value(&i), "/")
+			if typ == "any" {
+				continue
+			}
+
+			m := &nftICMPMatch{negate: n, v6: opt == "--icmpv6-type", hasCode: hasCode}
+			m.typ, err = parseNFTUint8(typ)
+			if hasCode && err == nil {
+				m.code, err = parseNFTUint8(code)
+			}
+			add(m, nil)
+		case "--tcp-flags":
+			m := &nftTCPFlagsMatch{negate: negated()}
+			m.mask, err = parseNFTTCPFlags(value(&i))
+			if err == nil {
+				m.comp, err = parseNFTTCPFlags(value(&i))
+			}
+			add(m, nil)
+		case "--tcp-option":
+			m := &nftTCPOptionMatch{negate: negated()}
+			m.kind, err = parseNFTUint8(value(&i))
+			add(m, nil)
+		case "--mac-source":
+			m := &nftMACMatch{negate: negated()}
+			m.mac, err = net.ParseMAC(value(&i))
+			add(m, nil)
+		case "--ctstate":
+			add(&nftStateMatch{negate: negated(), states: strings.Split(strings.ToLower(value(&i)), ",")}, nil)
+		case "--limit":
+			rate := value(&i)
+			opts := options(&i, map[string]bool{"--limit-burst": true})
+			add(parseNFTLimit(rate, opts["--limit-burst"]))
+		case "--hashlimit-upto":
+			rate := value(&i)
+			opts := options(&i, map[string]bool{
+				"--hashlimit-burst":   true,
+				"--hashlimit-mode":    true,
+				"--hashlimit-srcmask": true,
+				"--hashlimit-name":    true,
+			})
+			if opts["--hashlimit-mode"] != "srcip" {
+				err = fmt.Errorf("unsupported hashlimit mode '%s'", opts["--hashlimit-mode"])
+				break
+			}
+
+			m := &nftMeter{name: opts["--hashlimit-name"]}
+			m.limit, err = parseNFTLimit(rate, opts["--hashlimit-burst"])
+			if err == nil {
+				m.mask, err = parseNFTMask(family, opts["--hashlimit-srcmask"])
+			}
+			add(m, nil)
+		case "--connlimit-above":
+			above := value(&i)
+			opts := options(&i, map[string]bool{"--connlimit-mask": true, "--connlimit-saddr": false})
+
+			m := &nftMeter{name: nftMeterName(rulespec)}
+			var n uint64
+			if n, err = strconv.ParseUint(above, 10, 32); err == nil {
+				m.connOver = uint32(n)
+				m.mask, err = parseNFTMask(family, opts["--connlimit-mask"])
+			}
+			add(m, nil)
+		case "--comment":
+			parsed.comment = value(&i)
+		case "-g":
+			add(&nftVerdict{kind: "goto", chain: value(&i)}, nil)
+		case "-j":
+			switch target := value(&i); target {
+			case "ACCEPT", "DROP", "RETURN":
+				add(&nftVerdict{kind: strings.ToLower(target)}, nil)
+			case "REJECT":
+				opts := options(&i, map[string]bool{"--reject-with": true})
+				switch with := opts["--reject-with"]; with {
+				case "icmp-port-unreachable":
+					add(&nftReject{}, nil)
+				case "icmp6-port-unreachable":
+					add(&nftReject{icmpv6: true}, nil)
+				default:
+					err = fmt.Errorf("unsupported reject type '%s'", with)
+				}
+			case "LOG":
+				opts := options(&i, map[string]bool{"--log-prefix": true, "--log-level": true})
+				add(&nftLog{prefix: opts["--log-prefix"], level: opts["--log-level"]}, nil)
+			case "NFLOG":
+				opts := options(&i, map[string]bool{"--nflog-group": true, "--nflog-prefix": true})
+				var group uint64
+				group, err = strconv.ParseUint(opts["--nflog-group"], 10, 16)
+				add(&nftLog{prefix: opts["--nflog-prefix"], group: uint16(group), nflog: true}, nil)
+			default:
+				add(&nftVerdict{kind: "jump", chain: target}, nil)
+			}
+		default:
+			err = fmt.Errorf("unsupported option '%s'", opt)
+		}
+	}
+	return
+}
+
+func parseNFTPrefix(value string) (prefix netip.Prefix, err error) {
+	if strings.Contains(value, "/") {
+		if prefix, err = netip.ParsePrefix(value); err == nil {
+			prefix = prefix.Masked()
+		}
+		return
+	}
+
+	var addr netip.Addr
+	if addr, err = netip.ParseAddr(value); err == nil {
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	return
+}
+
+func parseNFTPortRange(value string) (r [2]uint16, err error) {
+	start, end, isRange := strings.Cut(value, ":")
+	var n uint64
+	if n, err = strconv.ParseUint(start, 10, 16); err != nil {
+		return
+	}
+	r[0], r[1] = uint16(n), uint16(n)
+
+	if isRange {
+		if n, err = strconv.ParseUint(end, 10, 16); err != nil {
+			return
+		}
+		r[1] = uint16(n)
+	}
+	return
+}
+
+func parseNFTUint8(value string) (n uint8, err error) {
+	var parsed uint64
+	parsed, err = strconv.ParseUint(value, 10, 8)
+	n = uint8(parsed)
+	return
+}
+
+// parseNFTTCPFlags parses comma separated tcp flag names, 'ALL' and 'NONE'
+func parseNFTTCPFlags(value string) (bits uint8, err error) {
+	for _, name := range strings.Split(value, ",") {
+		switch name {
+		case "ALL":
+			bits |= 0x3f
+		case "NONE":
+		default:
+			bit, ok := nftTCPFlagBits[name]
+			if !ok {
+				err = fmt.Errorf("unsupported tcp flag '%s'", name)
+				return
+			}
+			bits |= bit
+		}
+	}
+	return
+}
+
+func parseNFTMask(family, value string) (mask int, err error) {
+	if mask, err = strconv.Atoi(value); err == nil && (mask < 0 || mask > nftFullMask(family)) {
+		err = fmt.Errorf("invalid mask /%d", mask)
+	}
+	return
+}
+
+// parseNFTLimit parses rate normalized by rule package ('<n>/<unit>')
+func parseNFTLimit(rate, burst string) (limit *nftLimit, err error) {
+	limit = &nftLimit{}
+	amount, unit, _ := strings.Cut(rate, "/")
+	if !nftRateUnits[unit] {
+		err = fmt.Errorf("unsupported rate unit '%s'", unit)
+		return
+	}
+	limit.unit = unit
+
+	if limit.rate, err = strconv.ParseUint(amount, 10, 64); err != nil {
+		return
+	}
+
+	if burst != "" {
+		var n uint64
+		n, err = strconv.ParseUint(burst, 10, 32)
+		limit.burst = uint32(n)
+	}
+	return
+}
+
+func nftOp(negate bool) string {
+	if negate {
+		return "!= "
+	}
+	return ""
+}
+
+func (m *nftAddressMatch) text(family string) string {
+	direction := "saddr"
+	if m.destination {
+		direction = "daddr"
+	}
+	return fmt.Sprintf("%s %s %s%s", family, direction, nftOp(m.negate), m.prefix)
+}
+
+// nftProtocolName returns protocol name known to nftables
+func nftProtocolName(protocol string) string {
+	if protocol == "icmpv6" {
+		return "ipv6-icmp"
+	}
+	return protocol
+}
+
+func (m *nftProtocolMatch) text(family string) string {
+	return "meta l4proto " + nftOp(m.negate) + nftProtocolName(m.protocol)
+}
+
+func (m *nftPortMatch) text(family string) string {
+	var ports []string
+	for _, r := range m.ranges {
+		if r[0] == r[1] {
+			ports = append(ports, strconv.Itoa(int(r[0])))
+		} else {
+			ports = append(ports, fmt.Sprintf("%d-%d", r[0], r[1]))
+		}
+	}
+
+	set := ports[0]
+	if m.set {
+		set = "{ " + strings.Join(ports, ", ") + " }"
+	}
+	direction := "dport"
+	if m.source {
+		direction = "sport"
+	}
+	return fmt.Sprintf("%s %s %s%s", m.protocol, direction, nftOp(m.negate), set)
+}
+
+func (m *nftInterfaceMatch) text(family string) string {
+	key := "iifname"
+	if m.output {
+		key = "oifname"
+	}
+	return fmt.Sprintf("%s %s\"%s\"", key, nftOp(m.negate), m.name)
+}
+
+// nftTCPFlagNames renders flags as nftables flag expression
+func nftTCPFlagNames(bits uint8) string {
+	var names []string
+	for _, name := range nftTCPFlagOrder {
+		if bits&nftTCPFlagBits[name] != 0 {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+
+	if len(names) == 0 {
+		return "0x0"
+	}
+	return strings.Join(names, " | ")
+}
+
+func (m *nftTCPFlagsMatch) text(family string) string {
+	mask := nftTCPFlagNames(m.mask)
+	if strings.Contains(mask, " ") {
+		mask = "(" + mask + ")"
+	}
+
+	op := "=="
+	if m.negate {
+		op = "!="
+	}
+	return fmt.Sprintf("tcp flags & %s %s %s", mask, op, nftTCPFlagNames(m.comp))
+}
+
+func (m *nftTCPOptionMatch) text(family string) string {
+	if m.negate {
+		return fmt.Sprintf("tcp option %d missing", m.kind)
+	}
+	return fmt.Sprintf("tcp option %d exists", m.kind)
+}
+
+func (m *nftICMPMatch) name() string {
+	if m.v6 {
+		return "icmpv6"
+	}
+	return "icmp"
+}
+
+func (m *nftICMPMatch) text(family string) string {
+	s := fmt.Sprintf("%s type %s%d", m.name(), nftOp(m.negate), m.typ)
+	if m.hasCode {
+		s += fmt.Sprintf(" %s code %d", m.name(), m.code)
+	}
+	return s
+}
+
+func (m *nftMACMatch) text(family string) string {
+	return "ether saddr " + nftOp(m.negate) + m.mac.String()
+}
+
+func (m *nftStateMatch) text(family string) string {
+	return "ct state " + nftOp(m.negate) + strings.Join(m.states, ",")
+}
+
+func (l *nftLimit) text(family string) string {
+	if l.burst == 0 {
+		return fmt.Sprintf("limit rate %d/%s", l.rate, l.unit)
+	}
+	return fmt.Sprintf("limit rate %d/%s burst %d packets", l.rate, l.unit, l.burst)
+}
+
+func (m *nftMeter) text(family string) string {
+	source := family + " saddr"
+	if m.mask < nftFullMask(family) {
+		source += " and " + nftMask(family, m.mask).String()
+	}
+
+	statement := fmt.Sprintf("ct count over %d", m.connOver)
+	if m.limit != nil {
+		statement = m.limit.text(family)
+	}
+	return fmt.Sprintf("meter %s { %s %s }", m.name, source, statement)
+}
+
+func (v *nftVerdict) text(family string) string {
+	if v.chain != "" {
+		return v.kind + " " + v.chain
+	}
+	return v.kind
+}
+
+func (r *nftReject) text(family string) string {
+	if r.icmpv6 {
+		return "reject with " + nftRejectTypes["icmp6-port-unreachable"]
+	}
+	return "reject with " + nftRejectTypes["icmp-port-unreachable"]
+}
+
+func (l *nftLog) text(family string) string {
+	if l.nflog {
+		return fmt.Sprintf("log prefix \"%s\" group %d", l.prefix, l.group)
+	}
+
+	level := l.level
+	if nftLevel, ok := nftLogLevels[level]; ok {
+		level = nftLevel
+	}
+	return fmt.Sprintf("log prefix \"%s\" level %s", l.prefix, level)
+}
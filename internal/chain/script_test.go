@@ -0,0 +1,238 @@
+package chain_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/chain"
+	"github.com/ZentriaMC/swdfw/internal/cmdchain"
+	"github.com/ZentriaMC/swdfw/internal/golden"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+// Temporary chain names contain a time based suffix, unlike port ranges the name before it does not end with a digit
+var tempChainSuffix = regexp.MustCompile(`(\D):\d+\b`)
+
+// stableScript replaces time based parts of generated script
+func stableScript(script string) string {
+	return tempChainSuffix.ReplaceAllString(script, "${1}:TEMP")
+}
+
+func TestChainScripts(t *testing.T) {
+	rules := []rule.Rule{
+		{ID: "ssh", Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.0/24", "2001:db8::/32"), Ports: rule.MustParsePorts("ssh"), Action: "allow"},
+		{ID: "web", Protocol: "tcp", Ports: rule.MustParsePorts("80", "443"), Action: "allow"},
+		{ID: "block", Protocol: "all", Source: rule.MustParseCIDR("192.0.2.0/24"), Action: "block"},
+	}
+
+	cases := []struct {
+		name string
+		opts []chain.ChainManagerOpt
+		run  func(ctx context.Context, c chain.ChainManager) error
+	}{
+		{
+			name: "install-base-chain.sh",
+			run: func(ctx context.Context, c chain.ChainManager) error {
+				return c.InstallBaseChain(ctx, "SWDFW-INPUT", "INPUT")
+			},
+		},
+		{
+			name: "configure-chain.sh",
+			run: func(ctx context.Context, c chain.ChainManager) error {
+				return c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "", rules)
+			},
+		},
+		{
+			name: "configure-chain-options.sh",
+			opts: []chain.ChainManagerOpt{chain.WithLogBlocked(&rule.Log{Prefix: "blocked "})},
+			run: func(ctx context.Context, c chain.ChainManager) error {
+				return c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "SWDFW-DEFAULT", rules,
+					chain.WithConntrackPrelude(true),
+					chain.WithICMPv6NeighborDiscovery(true),
+				)
+			},
+		},
+		{
+			name: "delete-chain.sh",
+			run: func(ctx context.Context, c chain.ChainManager) error {
+				return c.DeleteChain(ctx, "basicrules")
+			},
+		},
+		{
+			name: "configure-chain-quirks.sh",
+			opts: []chain.ChainManagerOpt{chain.Quirks(chain.QuirkIPTablesBrokenChainCheck)},
+			run: func(ctx context.Context, c chain.ChainManager) error {
+				return c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "", rules)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sg := cmdchain.NewShellScriptGenerator("#!/bin/sh -e")
+			c, err := chain.NewChainManager(append([]chain.ChainManagerOpt{
+				chain.WithCustomExecutor(sg.Executor()),
+				chain.WithParallelism(1),
+				chain.VerifyIPTablesPath(false),
+			}, tc.opts...)...)
+			if err != nil {
+				t.Fatalf("failed to initialize chainmanager: %s", err)
+			}
+
+			if err := tc.run(context.Background(), c); err != nil {
+				t.Fatalf("failed to run: %s", err)
+			}
+
+			golden.Assert(t, filepath.Join("scripts", tc.name), stableScript(sg.Script()))
+		})
+	}
+}
+
+func TestChainScriptParallel(t *testing.T) {
+	rules := []rule.Rule{
+		{ID: "ssh", Protocol: "tcp", Ports: rule.MustParsePorts("ssh"), Action: "allow"},
+	}
+
+	scriptLines := func(opts ...chain.ChainManagerOpt) (lines []string) {
+		sg := cmdchain.NewShellScriptGenerator("#!/bin/sh -e")
+		c, err := chain.NewChainManager(append([]chain.ChainManagerOpt{
+			chain.WithCustomExecutor(sg.Executor()),
+			chain.VerifyIPTablesPath(false),
+		}, opts...)...)
+		if err != nil {
+			t.Fatalf("failed to initialize chainmanager: %s", err)
+		}
+
+		if err := c.ConfigureChain(context.Background(), "basicrules", "SWDFW-INPUT", "", rules); err != nil {
+			t.Fatalf("failed to configure chain: %s", err)
+		}
+
+		lines = strings.Split(stableScript(sg.Script()), "\n")
+		sort.Strings(lines)
+		return
+	}
+
+	// Address families run concurrently by default, script must contain the same commands in some order
+	sequential := scriptLines(chain.WithParallelism(1))
+	for i := 0; i < 20; i++ {
+		if concurrent := scriptLines(); !reflect.DeepEqual(concurrent, sequential) {
+			t.Fatalf("expected concurrent script to contain the same lines:\n%s", strings.Join(concurrent, "\n"))
+		}
+	}
+}
+
+func TestRulesetGenerator(t *testing.T) {
+	ctx := context.Background()
+	rules := []rule.Rule{
+		{ID: "ssh", Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.0/24", "2001:db8::/32"), Ports: rule.MustParsePorts("ssh"), Action: "allow",
+			RateLimit: &rule.RateLimit{Rate: "10/s", PerSource: true, SourceMask: 24}},
+		{ID: "web-limit", Protocol: "tcp", Ports: rule.MustParsePorts("80", "443", "8000-8999"), Action: "block",
+			ConnLimit: &rule.ConnLimit{Above: 100}},
+		{ID: "web", Protocol: "tcp", Ports: rule.MustParsePorts("80", "443", "8000-8999"), Action: "allow"},
+		{ID: "ping", Protocol: "icmp", Action: "allow", Flags: []string{"icmp:echo-request", "state:new"}},
+		{ID: "mac", Protocol: "udp", Source: rule.MustParseCIDR("0.0.0.0/0"), SourceMAC: "!02:00:00:00:00:01", Action: "block"},
+		{ID: "block", Protocol: "all", Source: rule.MustParseCIDR("192.0.2.0/24"), Action: "block", Comment: "'quoted' comment"},
+	}
+
+	rg := chain.NewRulesetGenerator()
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(rg.Executor()),
+		chain.WithLogBlocked(&rule.Log{Prefix: "blocked "}),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	if err := c.InstallBaseChain(ctx, "SWDFW-INPUT", "INPUT"); err != nil {
+		t.Fatalf("failed to install base chain: %s", err)
+	}
+
+	opts := []chain.ChainOpt{chain.WithConntrackPrelude(true), chain.WithICMPv6NeighborDiscovery(true)}
+	if err := c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "SWDFW-DEFAULT", rules, opts...); err == nil {
+		t.Fatal("expected error for missing jump target")
+	}
+
+	if err := c.InstallBaseChain(ctx, "SWDFW-DEFAULT", "INPUT"); err != nil {
+		t.Fatalf("failed to install base chain: %s", err)
+	}
+
+	// Second run replaces rules of the first one
+	for _, chainRules := range [][]rule.Rule{rules[:1], rules} {
+		if err := c.ConfigureChain(ctx, "basicrules", "SWDFW-INPUT", "SWDFW-DEFAULT", chainRules, opts...); err != nil {
+			t.Fatalf("failed to configure chain: %s", err)
+		}
+	}
+
+	nft, err := rg.NFT()
+	if err != nil {
+		t.Fatalf("failed to render nftables script: %s", err)
+	}
+
+	golden.Assert(t, filepath.Join("ruleset", "rules.v4"), rg.Restore(rule.ProtocolIPv4))
+	golden.Assert(t, filepath.Join("ruleset", "rules.v6"), rg.Restore(rule.ProtocolIPv6))
+	golden.Assert(t, filepath.Join("ruleset", "ruleset.nft"), nft)
+}
+
+func TestRulesetRenderers(t *testing.T) {
+	ctx := context.Background()
+	rules := []rule.Rule{
+		{ID: "tcp", Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.0/24", "2001:db8::/32"), Port: 22, Action: "allow"},
+		{ID: "udp", Protocol: "udp", Ports: rule.MustParsePorts("53", "8000-8999"), Action: "allow"},
+		{ID: "sctp", Protocol: "sctp", StartPort: 3868, Action: "allow"},
+		{ID: "udplite", Protocol: "udplite", StartPort: 5000, EndPort: 5010, Action: "allow"},
+		{ID: "icmp", Protocol: "icmp", Action: "allow", Flags: []string{"icmp:3/4"}},
+		{ID: "icmpv6", Protocol: "icmpv6", Action: "allow", Flags: []string{"icmpv6:echo-request"}},
+		{ID: "gre", Protocol: "gre", Action: "allow"},
+		{ID: "esp", Protocol: "esp", Action: "allow"},
+		{ID: "ah", Protocol: "ah", Action: "allow"},
+		{ID: "number", Protocol: "115", Action: "allow"},
+		{ID: "output", Protocol: "tcp", Direction: "output", Destination: rule.MustParseCIDR("198.51.100.0/24"), Port: 443, Action: "allow"},
+		{ID: "services", Protocol: "tcp", Ports: rule.MustParsePorts("ssh", "https"), Action: "allow"},
+		{ID: "states", Protocol: "tcp", Port: 25, Action: "allow", Flags: []string{"state:new", "state:established", "state:related"}},
+		{ID: "mac", Protocol: "udp", Port: 67, Action: "allow", SourceMAC: "02:00:00:00:00:01"},
+		{ID: "rate", Protocol: "icmp", Action: "allow", RateLimit: &rule.RateLimit{Rate: "5/s"}},
+		{ID: "hashlimit", Protocol: "tcp", Port: 2222, Action: "allow", RateLimit: &rule.RateLimit{Rate: "10/min", PerSource: true, SourceMask: 24}},
+		{ID: "connlimit", Protocol: "tcp", Port: 80, Action: "block", ConnLimit: &rule.ConnLimit{Above: 50}},
+		{ID: "log", Protocol: "tcp", Port: 23, Action: "block", Log: &rule.Log{Level: "info"}},
+		{ID: "nflog", Protocol: "udp", Port: 69, Action: "block", Log: &rule.Log{Target: "nflog", Group: 5}},
+		{ID: "invalid", Protocol: "all", Action: "block", Flags: []string{"state:invalid"}},
+		{ID: "block", Protocol: "all", Source: rule.MustParseCIDR("192.0.2.0/24", "2001:db8:bad::/48"), Action: "block", Comment: "blocked networks"},
+		{ID: "interface", Protocol: "tcp", Port: 22, Action: "allow", SourceInterface: "eth0"},
+		{ID: "sport", Protocol: "udp", SourcePort: 123, Port: 123, Action: "allow"},
+		{ID: "sports", Protocol: "udplite", SourceStartPort: 1024, SourceEndPort: 65535, Port: 5000, Action: "allow"},
+		{ID: "tcp-flags", Protocol: "tcp", Action: "block", Flags: []string{"tcp:syn", "tcp:fin"}},
+		{ID: "no-tcp-flags", Protocol: "tcp", Action: "block", Flags: []string{"tcp:none"}},
+		{ID: "tcp-option", Protocol: "tcp", Port: 22, Action: "allow", Flags: []string{"tcpopt:30"}},
+	}
+
+	rg := chain.NewRulesetGenerator()
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(rg.Executor()),
+		chain.WithLogBlocked(&rule.Log{Prefix: "blocked "}),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	if err := c.InstallBaseChain(ctx, "SWDFW-INPUT", "INPUT"); err != nil {
+		t.Fatalf("failed to install base chain: %s", err)
+	}
+
+	if err := c.ConfigureChain(ctx, "coverage", "SWDFW-INPUT", "", rules); err != nil {
+		t.Fatalf("failed to configure chain: %s", err)
+	}
+
+	nft, err := rg.NFT()
+	if err != nil {
+		t.Fatalf("failed to render nftables script: %s", err)
+	}
+
+	golden.Assert(t, filepath.Join("ruleset", "coverage.v4"), rg.Restore(rule.ProtocolIPv4))
+	golden.Assert(t, filepath.Join("ruleset", "coverage.v6"), rg.Restore(rule.ProtocolIPv6))
+	golden.Assert(t, filepath.Join("ruleset", "coverage.nft"), nft)
+}
@@ -0,0 +1,178 @@
+package chain_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alessio/shellescape"
+	"github.com/ory/dockertest/v3"
+
+	"github.com/ZentriaMC/swdfw/internal/chain"
+	"github.com/ZentriaMC/swdfw/internal/rule"
+	"github.com/ZentriaMC/swdfw/internal/simulate"
+)
+
+func dockerShell(command ...string) (stdout string, err error) {
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	var exitCode int
+	exitCode, err = dockerResources["iptables"].Exec([]string{"/bin/sh", "-c", shellescape.QuoteCommand(command)}, dockertest.ExecOptions{
+		StdOut: &out,
+		StdErr: &stderr,
+	})
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("'%s' exited with status %d: %s", strings.Join(command, " "), exitCode, stderr.String())
+	}
+	stdout = out.String()
+	return
+}
+
+// sendPacket makes the container send given packet to itself over loopback
+func sendPacket(packet rule.Packet) {
+	src, dst := packet.Source.String(), packet.Destination.String()
+	port := strconv.Itoa(int(packet.DestinationPort))
+
+	switch packet.Protocol {
+	case "tcp":
+		command := []string{"nc", "-z", "-w", "1", "-s", src}
+		if packet.SourcePort != 0 {
+			command = append(command, "-p", strconv.Itoa(int(packet.SourcePort)))
+		}
+		_, _ = dockerShell(append(command, dst, port)...)
+	case "udp":
+		_, _ = dockerResources["iptables"].Exec([]string{"/bin/sh", "-c", fmt.Sprintf("echo x | nc -u -w 1 -s %s %s %s", src, dst, port)}, dockertest.ExecOptions{})
+	case "icmp":
+		_, _ = dockerShell("ping", "-c", "1", "-W", "1", "-I", src, dst)
+	}
+}
+
+// matchedRule returns rule id (or jump target) of the first rule in chain which matched a packet
+func matchedRule(chainName string) (matched string, target string, err error) {
+	var saved string
+	if saved, err = dockerShell("iptables-save", "-c", "-t", "filter"); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(saved))
+	for scanner.Scan() {
+		line := scanner.Text()
+		counters, spec, ok := strings.Cut(line, " -A "+chainName+" ")
+		if !ok || strings.HasPrefix(counters, "[0:") {
+			continue
+		}
+
+		if _, jumpTo, ok := strings.Cut(spec, "-g "); ok {
+			return "", jumpTo, nil
+		}
+
+		_, comment, _ := strings.Cut(spec, "--comment \"")
+		comment, _, _ = strings.Cut(comment, "\"")
+		_, matched, _, _ = rule.ParseComment(comment)
+
+		_, target, _ = strings.Cut(spec, "-j ")
+		target, _, _ = strings.Cut(target, " ")
+		return
+	}
+	return
+}
+
+func TestSimulateCorpusDocker(t *testing.T) {
+	if !hasDocker {
+		t.SkipNow()
+	}
+
+	data, err := os.ReadFile("../simulate/testdata/corpus.json")
+	if err != nil {
+		t.Fatalf("failed to read corpus: %s", err)
+	}
+
+	var scenarios []struct {
+		Name    string      `json:"name"`
+		JumpTo  string      `json:"jump_to"`
+		Rules   []rule.Rule `json:"rules"`
+		Packets []struct {
+			Packet rule.Packet `json:"packet"`
+		} `json:"packets"`
+	}
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		t.Fatalf("failed to parse corpus: %s", err)
+	}
+
+	c, err := chain.NewChainManager(
+		chain.WithCustomExecutor(dockerExecutor),
+		chain.WithProtocols(rule.ProtocolIPv4),
+		chain.VerifyIPTablesPath(false),
+	)
+	if err != nil {
+		t.Fatalf("failed to initialize chainmanager: %s", err)
+	}
+
+	ctx := context.Background()
+	baseInput := "SWDFWSIM-INPUT"
+	if err := c.InstallBaseChain(ctx, baseInput, "INPUT"); err != nil {
+		t.Fatalf("failed to install base input chain: %s", err)
+	}
+
+	// Only the first packet of a connection is of interest, replies must not reach managed chains
+	if _, err := dockerShell("iptables", "-I", "INPUT", "1", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"); err != nil {
+		t.Fatalf("failed to set up conntrack bypass: %s", err)
+	}
+
+	for _, scenario := range scenarios {
+		if scenario.JumpTo != "" {
+			_, _ = dockerShell("iptables", "-N", scenario.JumpTo)
+		}
+
+		chainName := "sim-" + scenario.Name
+		if err := c.ConfigureChain(ctx, chainName, baseInput, scenario.JumpTo, scenario.Rules); err != nil {
+			t.Fatalf("%s: failed to configure chain: %s", scenario.Name, err)
+		}
+
+		for i, p := range scenario.Packets {
+			packet := p.Packet
+			expected, err := simulate.Evaluate(scenario.Rules, scenario.JumpTo, packet)
+			if err != nil {
+				t.Errorf("%s/%d: failed to evaluate: %s", scenario.Name, i, err)
+				continue
+			}
+
+			for _, addr := range []string{packet.Source.String(), packet.Destination.String()} {
+				if _, err := dockerShell("ip", "addr", "replace", addr+"/32", "dev", "lo"); err != nil {
+					t.Fatalf("failed to add address: %s", err)
+				}
+			}
+
+			if _, err := dockerShell("iptables", "-Z", chainName); err != nil {
+				t.Fatalf("failed to zero counters: %s", err)
+			}
+
+			sendPacket(packet)
+
+			matched, target, err := matchedRule(chainName)
+			if err != nil {
+				t.Fatalf("failed to read counters: %s", err)
+			}
+
+			var expectedID string
+			if expected.Rule != nil {
+				expectedID = expected.Rule.ID
+			}
+
+			if matched != expectedID || target != expected.Target {
+				t.Errorf("%s/%d: simulated %s by '%s' (%s), kernel matched '%s' (%s)", scenario.Name, i, expected.Verdict, expectedID, expected.Target, matched, target)
+			}
+		}
+
+		_, _ = dockerShell("iptables", "-D", baseInput, "-g", chainName)
+		if err := c.DeleteChain(ctx, chainName); err != nil {
+			t.Logf("failed to delete chain: %s", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+#!/bin/sh
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -S SWDFW-INPUT 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -N SWDFW-INPUT
+fi
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -C INPUT -j SWDFW-INPUT 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -A INPUT -j SWDFW-INPUT
+fi
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -S basicrules:TEMP 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -N basicrules:TEMP
+fi
+iptables --wait 1 -t filter -A basicrules:TEMP -s 10.123.0.0/24 -p tcp --dport 22 -j RETURN -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"''
+iptables --wait 1 -t filter -A basicrules:TEMP -s 0.0.0.0/0 -p tcp --dport 1024:4096 -j RETURN -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"''
+iptables --wait 1 -t filter -A basicrules:TEMP -s 0.0.0.0/0 -p tcp -j REJECT --reject-with icmp-port-unreachable -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"''
+iptables --wait 1 -t filter -I SWDFW-INPUT -g basicrules:TEMP
+swdfw_status=0
+{ swdfw_stderr=$(iptables --wait 1 -t filter -D SWDFW-INPUT -g basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -S basicrules 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:*) ;;
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -F basicrules
+  iptables --wait 1 -t filter -X basicrules
+fi
+iptables --wait 1 -t filter -E basicrules:TEMP basicrules
@@ -0,0 +1,58 @@
+#!/bin/sh -e
+swdfw_status=0
+{ swdfw_stderr=$(iptables --wait 1 -t filter -N basicrules:TEMP 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Chain already exists.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_status=0
+{ swdfw_stderr=$(ip6tables --wait 1 -t filter -N basicrules:TEMP 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Chain already exists.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+iptables --wait 1 -t filter -A basicrules:TEMP -s 10.123.0.0/24 -p tcp --dport 22 -j RETURN -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"' id '"'"'ssh'"'"''
+ip6tables --wait 1 -t filter -A basicrules:TEMP -s 2001:db8::/32 -p tcp --dport 22 -j RETURN -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"' id '"'"'ssh'"'"''
+iptables --wait 1 -t filter -A basicrules:TEMP -s 0.0.0.0/0 -p tcp -m multiport --dports 80,443 -j RETURN -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"' id '"'"'web'"'"''
+ip6tables --wait 1 -t filter -A basicrules:TEMP -s ::/0 -p tcp -m multiport --dports 80,443 -j RETURN -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"' id '"'"'web'"'"''
+iptables --wait 1 -t filter -A basicrules:TEMP -s 192.0.2.0/24 -p all -j REJECT --reject-with icmp-port-unreachable -m comment --comment 'Autogenerated rule using swdfw from '"'"'basicrules'"'"' id '"'"'block'"'"''
+iptables --wait 1 -t filter -I SWDFW-INPUT -g basicrules:TEMP
+ip6tables --wait 1 -t filter -I SWDFW-INPUT -g basicrules:TEMP
+swdfw_status=0
+{ swdfw_stderr=$(iptables --wait 1 -t filter -D SWDFW-INPUT -g basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_status=0
+{ swdfw_stderr=$(ip6tables --wait 1 -t filter -D SWDFW-INPUT -g basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_status=0
+{ swdfw_stderr=$(iptables --wait 1 -t filter -F basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_status=0
+{ swdfw_stderr=$(iptables --wait 1 -t filter -X basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_status=0
+{ swdfw_stderr=$(ip6tables --wait 1 -t filter -F basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+swdfw_status=0
+{ swdfw_stderr=$(ip6tables --wait 1 -t filter -X basicrules 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:* | 1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+esac
+iptables --wait 1 -t filter -E basicrules:TEMP basicrules
+ip6tables --wait 1 -t filter -E basicrules:TEMP basicrules
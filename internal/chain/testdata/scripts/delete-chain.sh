@@ -0,0 +1,25 @@
+#!/bin/sh -e
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -S basicrules 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:*) ;;
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -F basicrules
+  iptables --wait 1 -t filter -X basicrules
+fi
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(ip6tables --wait 1 -t filter -S basicrules 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:*) ;;
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  ip6tables --wait 1 -t filter -F basicrules
+  ip6tables --wait 1 -t filter -X basicrules
+fi
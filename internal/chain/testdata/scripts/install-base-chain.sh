@@ -0,0 +1,45 @@
+#!/bin/sh -e
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -S SWDFW-INPUT 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -N SWDFW-INPUT
+fi
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(ip6tables --wait 1 -t filter -S SWDFW-INPUT 1 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  ip6tables --wait 1 -t filter -N SWDFW-INPUT
+fi
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(iptables --wait 1 -t filter -C INPUT -j SWDFW-INPUT 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  iptables --wait 1 -t filter -A INPUT -j SWDFW-INPUT
+fi
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(ip6tables --wait 1 -t filter -C INPUT -j SWDFW-INPUT 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'Bad rule (does a matching rule exist in that chain?).'* | 1:*'No chain/target/match by that name.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  ip6tables --wait 1 -t filter -A INPUT -j SWDFW-INPUT
+fi
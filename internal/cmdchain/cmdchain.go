@@ -21,6 +21,7 @@ type CommandChain interface {
 	WithCheck(name string, chain ChainChildFunc) CommandChain
 	WithExecutor(executor Executor) CommandChain
 	WithErrInterceptor(interceptor ErrInterceptor) CommandChain
+	WithTolerated(tolerated ...ToleratedError) CommandChain
 	WithOutput(stdout, stderr io.Writer) CommandChain
 	Args(args ...string) CommandChain
 	ArgsGroup(children ...ChainChildFunc) CommandChain
@@ -43,10 +44,13 @@ type cmdChain struct {
 	ctx         context.Context
 	executor    Executor
 	interceptor ErrInterceptor
+	tolerated   []ToleratedError
 	name        string
 	doChecks    bool
 	negated     bool
 	checks      []CommandChain
+	// Chain this one is a check or a group child of
+	owner *cmdChain
 
 	stdout   io.Writer
 	stderr   io.Writer
@@ -78,12 +82,7 @@ func (c *cmdChain) WithNegated(negated bool) CommandChain {
 }
 
 func (c *cmdChain) WithCheck(name string, chainFunc ChainChildFunc) CommandChain {
-	checkChain := chainFunc(
-		NewCommandChain(asCheck(c.ctx, c), name).
-			WithOutput(c.stdout, c.stderr).
-			WithExecutor(c.executor).
-			WithErrInterceptor(c.interceptor),
-	)
+	checkChain := chainFunc(c.newChild(asCheck(c.ctx, c), name))
 	c.checks = append(c.checks, checkChain)
 	return c
 }
@@ -99,8 +98,20 @@ func (c *cmdChain) WithExecutor(executor Executor) CommandChain {
 	return c
 }
 
+// WithErrInterceptor sets error interceptor, nil resets it to DefaultErrInterceptor
 func (c *cmdChain) WithErrInterceptor(interceptor ErrInterceptor) CommandChain {
+	if interceptor == nil {
+		interceptor = DefaultErrInterceptor
+	}
 	c.interceptor = interceptor
+	c.tolerated = nil
+	return c
+}
+
+// WithTolerated sets error interceptor tolerating given errors, see Tolerate
+func (c *cmdChain) WithTolerated(tolerated ...ToleratedError) CommandChain {
+	c.interceptor = Tolerate(tolerated...)
+	c.tolerated = tolerated
 	return c
 }
 
@@ -122,10 +133,7 @@ func (c *cmdChain) Args(args ...string) CommandChain {
 func (c *cmdChain) ArgsGroup(children ...ChainChildFunc) CommandChain {
 	for _, childFunc := range children {
 		ctx := context.WithValue(c.ctx, ContextParent, c)
-		child := childFunc(NewCommandChain(ctx, fmt.Sprintf("%s-child-%d", c.Name(), len(c.children))).
-			WithOutput(c.stdout, c.stderr).
-			WithExecutor(c.executor).
-			WithErrInterceptor(c.interceptor))
+		child := childFunc(c.newChild(ctx, fmt.Sprintf("%s-child-%d", c.Name(), len(c.children))))
 		c.children = append(c.children, child)
 	}
 	return c
@@ -137,6 +145,11 @@ func (c *cmdChain) Run() (err error) {
 		return
 	}
 
+	// Chains stop at step boundaries when cancelled
+	if err = c.ctx.Err(); err != nil {
+		return
+	}
+
 	ctx := context.WithValue(c.ctx, ContextSelf, c)
 	if c.doChecks {
 		for _, check := range c.checks {
@@ -156,8 +169,12 @@ func (c *cmdChain) Run() (err error) {
 	}
 
 	if len(c.args) > 0 {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
 		ctx = withInputOutput(ctx, c.stdout, c.stderr)
-		err = c.interceptor(c.executor(ctx, c.args...))
+		err = c.outcome(c.executor(ctx, c.args...))
 	} else if len(c.children) > 0 {
 		for _, child := range c.children {
 			cerr := child.Run()
@@ -167,6 +184,52 @@ func (c *cmdChain) Run() (err error) {
 	return
 }
 
+// outcome maps command error to chain result, applying negation or error interceptor
+func (c *cmdChain) outcome(err error) error {
+	if c.negated {
+		return c.negate(err)
+	}
+	return c.interceptor(err)
+}
+
+// negate inverts result of a negated chain's command: success short circuits the owning chain, failure lets it
+// continue. When tolerated errors are set, only these count as failures and other errors are returned as they are.
+func (c *cmdChain) negate(err error) error {
+	if err == nil {
+		return ErrShortCircuit
+	}
+
+	if len(c.tolerated) > 0 {
+		if ierr := c.interceptor(err); ierr != nil && !errors.Is(ierr, ErrShortCircuit) {
+			return ierr
+		}
+	}
+	return nil
+}
+
 func (c *cmdChain) Negated() bool {
 	return c.negated
 }
+
+// newChild creates a check or a group child chain inheriting output, executor and error interceptor
+func (c *cmdChain) newChild(ctx context.Context, name string) *cmdChain {
+	return &cmdChain{
+		ctx:         ctx,
+		executor:    c.executor,
+		interceptor: c.interceptor,
+		tolerated:   c.tolerated,
+		name:        name,
+		doChecks:    true,
+		stdout:      c.stdout,
+		stderr:      c.stderr,
+		owner:       c,
+	}
+}
+
+// root returns the outermost chain this chain is a check or a group child of
+func (c *cmdChain) root() *cmdChain {
+	for c.owner != nil {
+		c = c.owner
+	}
+	return c
+}
@@ -2,9 +2,16 @@ package cmdchain_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"github.com/ZentriaMC/swdfw/internal/cmdchain"
@@ -78,3 +85,502 @@ func TestChainWithChecksDisabled(t *testing.T) {
 		t.Error("check 'check0' was not supposed to be ran")
 	}
 }
+
+func TestChainNegatedCheck(t *testing.T) {
+	ctx := context.Background()
+	var collected []string
+	existing := map[string]bool{"present": true}
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		if args[0] == "exists" {
+			if !existing[args[1]] {
+				return &cmdchain.ChainExecError{Args: args, Status: 1}
+			}
+			return nil
+		}
+		collected = append(collected, args[1])
+		return nil
+	}
+
+	for _, name := range []string{"present", "missing"} {
+		err := cmdchain.NewCommandChain(ctx, "create-if-missing").
+			WithExecutor(collectingExecutor).
+			WithCheck("missing-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.WithNegated(true).Args("exists", name)
+			}).
+			Args("create", name).
+			Run()
+		if err != nil {
+			t.Error("unexpected err:", err)
+		}
+	}
+
+	if strings.Join(collected, ",") != "missing" {
+		t.Errorf("expected only missing entry to be created, got %v", collected)
+	}
+}
+
+func TestChainNegatedCheckTolerated(t *testing.T) {
+	ctx := context.Background()
+	var collected []string
+	var executor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		switch args[0] {
+		case "exists":
+			switch args[1] {
+			case "present":
+				return nil
+			case "missing":
+				return &cmdchain.ChainExecError{Args: args, Stderr_: "No such thing.\n", Status: 1}
+			default:
+				return &cmdchain.ChainExecError{Args: args, Stderr_: "Resource busy.\n", Status: 4}
+			}
+		}
+		collected = append(collected, args[1])
+		return nil
+	}
+
+	run := func(name string) error {
+		return cmdchain.NewCommandChain(ctx, "create-if-missing").
+			WithExecutor(executor).
+			WithCheck("missing-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.
+					WithNegated(true).
+					WithTolerated(cmdchain.ToleratedError{Status: 1, Stderr: "No such thing."}).
+					Args("exists", name)
+			}).
+			Args("create", name).
+			Run()
+	}
+
+	for _, name := range []string{"present", "missing"} {
+		if err := run(name); err != nil {
+			t.Errorf("unexpected err for '%s': %s", name, err)
+		}
+	}
+
+	// Only tolerated errors mean missing, others fail the check
+	var cmdErr *cmdchain.ChainExecError
+	if err := run("busy"); !errors.As(err, &cmdErr) || cmdErr.Stderr() != "Resource busy.\n" {
+		t.Errorf("expected check to fail with command error, got %v", err)
+	}
+
+	if strings.Join(collected, ",") != "missing" {
+		t.Errorf("expected only missing entry to be created, got %v", collected)
+	}
+}
+
+func TestShellScriptGenerator(t *testing.T) {
+	ctx := context.Background()
+	sg := cmdchain.NewShellScriptGenerator("#!/bin/sh -e")
+
+	err := cmdchain.NewCommandChain(ctx, "nested").
+		WithExecutor(sg.Executor()).
+		WithSimpleCheck("precondition", "test", "-x", "/sbin/iptables").
+		WithCheck("missing-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+			return cc.
+				WithNegated(true).
+				WithOutput(io.Discard, io.Discard).
+				WithSimpleCheck("inner-check", "true").
+				Args("grep", "-q", "it's here", "/etc/file")
+		}).
+		ArgsGroup(
+			func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.Args("echo", "first")
+			},
+			func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.
+					WithCheck("child-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+						return cc.WithNegated(true).Args("false")
+					}).
+					Args("echo", "second")
+			},
+		).
+		Run()
+	if err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+
+	err = cmdchain.NewCommandChain(ctx, "simple").
+		WithExecutor(sg.Executor()).
+		Args("echo", "done").
+		Run()
+	if err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+
+	expected := `#!/bin/sh -e
+test -x /sbin/iptables
+if ! {
+  true >/dev/null 2>/dev/null
+  grep -q 'it'"'"'s here' /etc/file >/dev/null 2>/dev/null
+}; then
+  echo first
+  if ! false; then
+    echo second
+  fi
+fi
+echo done
+`
+	if script := sg.Script(); script != expected {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+}
+
+func TestChainTolerated(t *testing.T) {
+	ctx := context.Background()
+	var collected []string
+	var failingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		if args[0] == "exists" {
+			return &cmdchain.ChainExecError{Args: args, Stderr_: "No such thing.\n", Status: 1}
+		}
+		collected = append(collected, args[0])
+		return nil
+	}
+
+	run := func(tolerated ...cmdchain.ToleratedError) error {
+		return cmdchain.NewCommandChain(ctx, "tolerated").
+			WithExecutor(failingExecutor).
+			WithCheck("exists-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.WithTolerated(tolerated...).Args("exists")
+			}).
+			Args("body").
+			Run()
+	}
+
+	if err := run(cmdchain.ToleratedError{Status: 1, Stderr: "No such thing."}); err != nil {
+		t.Error("unexpected err:", err)
+	}
+
+	if err := run(cmdchain.ToleratedError{Status: 1, Stderr: "No such thing.", Short: true}); err != nil {
+		t.Error("unexpected err:", err)
+	}
+
+	if err := run(cmdchain.ToleratedError{Status: 2, Stderr: "No such thing."}); err == nil {
+		t.Error("expected error for mismatching status")
+	}
+
+	if strings.Join(collected, ",") != "body" {
+		t.Errorf("expected body to run only once, got %v", collected)
+	}
+}
+
+func TestShellScriptGeneratorInterleaved(t *testing.T) {
+	ctx := context.Background()
+	sg := cmdchain.NewShellScriptGenerator("#!/bin/sh -e")
+
+	other := cmdchain.NewCommandChain(ctx, "other").
+		WithExecutor(sg.Executor()).
+		Args("echo", "other")
+
+	// Other chain runs in between commands of the first one, like chains running concurrently do
+	interleaved := false
+	executor := func(ctx context.Context, command ...string) error {
+		if err := sg.Executor()(ctx, command...); err != nil || interleaved {
+			return err
+		}
+		interleaved = true
+		return other.Run()
+	}
+
+	err := cmdchain.NewCommandChain(ctx, "first").
+		WithExecutor(executor).
+		WithSimpleCheck("precondition", "true").
+		Args("echo", "first").
+		Run()
+	if err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+
+	expected := `#!/bin/sh -e
+true
+echo first
+echo other
+`
+	if script := sg.Script(); script != expected {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+}
+
+func TestShellScriptGeneratorTolerated(t *testing.T) {
+	ctx := context.Background()
+	sg := cmdchain.NewShellScriptGenerator("#!/bin/sh -e")
+
+	err := cmdchain.NewCommandChain(ctx, "tolerated").
+		WithExecutor(sg.Executor()).
+		WithCheck("exists-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+			return cc.
+				WithTolerated(cmdchain.ToleratedError{Status: 1, Stderr: "No such thing.", Short: true}).
+				WithOutput(io.Discard, nil).
+				Args("exists", "thing")
+		}).
+		WithTolerated(cmdchain.ToleratedError{Status: 1, Stderr: "Already there."}).
+		Args("create", "thing").
+		Run()
+	if err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+
+	expected := `#!/bin/sh -e
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(exists thing 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+0:*) ;;
+1:*'No such thing.'*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  swdfw_status=0
+  { swdfw_stderr=$(create thing 2>&1 1>&3 3>&-); } 3>&1 || swdfw_status=$?
+  case "$swdfw_status:$swdfw_stderr" in
+  0:* | 1:*'Already there.'*) ;;
+  *) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status") ;;
+  esac
+fi
+`
+	if script := sg.Script(); script != expected {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+}
+
+func TestShellScriptGeneratorNegatedTolerated(t *testing.T) {
+	ctx := context.Background()
+	sg := cmdchain.NewShellScriptGenerator("#!/bin/sh -e")
+
+	err := cmdchain.NewCommandChain(ctx, "create-if-missing").
+		WithExecutor(sg.Executor()).
+		WithCheck("missing-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+			return cc.
+				WithNegated(true).
+				WithTolerated(cmdchain.ToleratedError{Status: 1, Stderr: "No such thing."}).
+				WithOutput(io.Discard, nil).
+				Args("exists", "thing")
+		}).
+		Args("create", "thing").
+		Run()
+	if err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+
+	// Only tolerated error means missing, other errors fail the script
+	expected := `#!/bin/sh -e
+swdfw_pass0=true
+swdfw_status=0
+swdfw_stderr=$(exists thing 2>&1 >/dev/null) || swdfw_status=$?
+case "$swdfw_status:$swdfw_stderr" in
+1:*'No such thing.'*) ;;
+0:*) swdfw_pass0=false ;;
+*) printf '%s\n' "$swdfw_stderr" >&2; (exit "$swdfw_status"); swdfw_pass0=false ;;
+esac
+if "$swdfw_pass0"; then
+  create thing
+fi
+`
+	if script := sg.Script(); script != expected {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+}
+
+func TestTraceRecorder(t *testing.T) {
+	ctx := context.Background()
+	var failingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		if args[0] == "fail" {
+			return &cmdchain.ChainExecError{Args: args, Stderr_: args[1], Status: 1}
+		}
+		return nil
+	}
+	tr := cmdchain.NewTraceRecorder(failingExecutor)
+
+	err := cmdchain.NewCommandChain(ctx, "main").
+		WithExecutor(tr.Executor()).
+		WithCheck("missing-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+			return cc.WithNegated(true).Args("fail", "missing")
+		}).
+		ArgsGroup(
+			func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.
+					WithTolerated(cmdchain.ToleratedError{Status: 1, Stderr: "exists"}).
+					Args("fail", "exists")
+			},
+			func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+				return cc.Args("fail", "broken")
+			},
+		).
+		Run()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	entries := tr.Entries()
+	expected := []struct {
+		chain, parent, checking string
+		decision                cmdchain.TraceDecision
+	}{
+		{"missing-check", "", "main", cmdchain.DecisionPassed},
+		{"main-child-0", "main", "", cmdchain.DecisionTolerated},
+		{"main-child-1", "main", "", cmdchain.DecisionFailed},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+	}
+
+	for i, e := range expected {
+		entry := entries[i]
+		if entry.Chain != e.chain || entry.Parent != e.parent || entry.Checking != e.checking || entry.Decision != e.decision {
+			t.Errorf("unexpected entry %d: %+v", i, entry)
+		}
+		if entry.Status != 1 || entry.Stderr != entry.Args[1] || entry.End.Before(entry.Start) {
+			t.Errorf("unexpected outcome in entry %d: %+v", i, entry)
+		}
+	}
+
+	if !entries[0].Negated {
+		t.Error("expected check entry to be negated")
+	}
+
+	var sb strings.Builder
+	if err := tr.WriteJSONLines(&sb); err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(sb.String()), "\n"); len(lines) != len(expected) {
+		t.Errorf("expected %d lines, got %d", len(expected), len(lines))
+	}
+
+	tr.Reset()
+	err = cmdchain.NewCommandChain(ctx, "main").
+		WithExecutor(tr.Executor()).
+		WithCheck("existing-check", func(cc cmdchain.CommandChain) cmdchain.CommandChain {
+			return cc.WithNegated(true).Args("exists")
+		}).
+		Args("create").
+		Run()
+	if err != nil {
+		t.Fatal("unexpected err:", err)
+	}
+
+	if entries := tr.Entries(); len(entries) != 1 || entries[0].Decision != cmdchain.DecisionShortCircuit {
+		t.Errorf("expected succeeding negated check to short circuit, got %+v", entries)
+	}
+}
+
+func TestNilErrInterceptor(t *testing.T) {
+	ctx := context.Background()
+	var failingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		return &cmdchain.ChainExecError{Args: args, Status: 1}
+	}
+	tr := cmdchain.NewTraceRecorder(failingExecutor)
+
+	err := cmdchain.NewCommandChain(ctx, "main").
+		WithExecutor(tr.Executor()).
+		WithErrInterceptor(nil).
+		Args("fail").
+		Run()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if entries := tr.Entries(); len(entries) != 1 || entries[0].Decision != cmdchain.DecisionFailed {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	fn := func(i int) func(context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			// Later functions finish first
+			time.Sleep(time.Duration(5-i) * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return fmt.Errorf("fn %d", i)
+		}
+	}
+
+	var fns []func(context.Context) error
+	for i := 0; i < 5; i++ {
+		fns = append(fns, fn(i))
+	}
+
+	err := cmdchain.RunParallel(ctx, 2, fns...)
+	if err == nil || err.Error() != "fn 0; fn 1; fn 2; fn 3; fn 4" {
+		t.Errorf("unexpected err: %v", err)
+	}
+
+	if maxRunning != 2 {
+		t.Errorf("expected at most 2 functions running at once, got %d", maxRunning)
+	}
+}
+
+func TestRunParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var collectingExecutor cmdchain.Executor = func(ctx context.Context, args ...string) error {
+		// First command cancels the rest
+		cancel()
+		return nil
+	}
+
+	var chains []cmdchain.CommandChain
+	for i := 0; i < 3; i++ {
+		chains = append(chains, cmdchain.NewCommandChain(ctx, fmt.Sprintf("chain-%d", i)).
+			WithExecutor(collectingExecutor).
+			Args("true"))
+	}
+
+	errs := multierr.Errors(cmdchain.RunChains(ctx, 1, chains...))
+	if len(errs) != 2 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("expected remaining chains to be cancelled, got %v", errs)
+	}
+}
+
+func TestRetrying(t *testing.T) {
+	errBusy := &cmdchain.ChainExecError{Status: 4, Stderr_: "busy"}
+	policy := cmdchain.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         0.5,
+		Retriable: func(err error) bool {
+			return errors.Is(err, errBusy)
+		},
+	}
+
+	attempts := 0
+	failing := func(failures int, failure error) cmdchain.Executor {
+		attempts = 0
+		return func(ctx context.Context, args ...string) error {
+			if attempts++; attempts <= failures {
+				return failure
+			}
+			return nil
+		}
+	}
+
+	if err := cmdchain.Retrying(failing(2, errBusy), policy)(context.Background(), "cmd"); err != nil || attempts != 3 {
+		t.Errorf("expected success after 3 attempts, got err=%v after %d attempts", err, attempts)
+	}
+
+	if err := cmdchain.Retrying(failing(3, errBusy), policy)(context.Background(), "cmd"); err == nil || attempts != 3 {
+		t.Errorf("expected failure after 3 attempts, got err=%v after %d attempts", err, attempts)
+	}
+
+	errOther := errors.New("other")
+	if err := cmdchain.Retrying(failing(1, errOther), policy)(context.Background(), "cmd"); err != errOther || attempts != 1 {
+		t.Errorf("expected non-retriable error to be returned right away, got err=%v after %d attempts", err, attempts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cmdchain.Retrying(failing(1, errBusy), policy)(ctx, "cmd"); !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("expected cancelled context to stop retrying, got err=%v after %d attempts", err, attempts)
+	}
+}
@@ -1,5 +1,10 @@
 package cmdchain
 
+import (
+	"errors"
+	"strings"
+)
+
 type ErrInterceptor func(error) error
 
 var (
@@ -7,3 +12,37 @@ var (
 		return err
 	}
 )
+
+// ToleratedError describes a command failure tolerated by an interceptor: exit status and a stderr substring.
+// Unlike arbitrary interceptors these can be replicated by script generators.
+type ToleratedError struct {
+	Status int
+	Stderr string
+	// Short circuit main execution instead of passing, see ErrShortCircuit
+	Short bool
+}
+
+func (t ToleratedError) matches(err error) bool {
+	var cmdErr *ChainExecError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitStatus() != t.Status {
+		return false
+	}
+	return strings.Contains(cmdErr.Stderr(), t.Stderr)
+}
+
+// Tolerate returns an interceptor passing (or short circuiting) errors matching any of tolerated
+func Tolerate(tolerated ...ToleratedError) ErrInterceptor {
+	return func(err error) error {
+		for _, t := range tolerated {
+			if !t.matches(err) {
+				continue
+			}
+
+			if t.Short {
+				return ErrShortCircuit
+			}
+			return nil
+		}
+		return err
+	}
+}
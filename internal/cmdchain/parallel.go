@@ -0,0 +1,60 @@
+package cmdchain
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// RunParallel runs independent functions concurrently, at most parallelism of them at once (no limit when
+// parallelism <= 0, sequentially in given order when 1). Functions not started before ctx is done are skipped and
+// report ctx error. Errors are combined in order of functions, regardless of the order they finish in.
+func RunParallel(ctx context.Context, parallelism int, fns ...func(context.Context) error) (err error) {
+	if parallelism <= 0 || parallelism > len(fns) {
+		parallelism = len(fns)
+	}
+
+	errs := make([]error, len(fns))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	for i, fn := range fns {
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, fn func(context.Context) error) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = fn(ctx)
+		}(i, fn)
+	}
+	wg.Wait()
+
+	for _, ferr := range errs {
+		err = multierr.Append(err, ferr)
+	}
+	return
+}
+
+// RunChains runs independent command chains using RunParallel
+func RunChains(ctx context.Context, parallelism int, chains ...CommandChain) (err error) {
+	fns := make([]func(context.Context) error, len(chains))
+	for i, chain := range chains {
+		fns[i] = func(chain CommandChain) func(context.Context) error {
+			return func(context.Context) error {
+				return chain.Run()
+			}
+		}(chain)
+	}
+	return RunParallel(ctx, parallelism, fns...)
+}
@@ -0,0 +1,66 @@
+package cmdchain
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// RetryPolicy describes how failed commands are retried, see Retrying
+type RetryPolicy struct {
+	// Maximum amount of attempts, including the first one
+	MaxAttempts int
+	// Delay before the second attempt, doubled for every following attempt
+	InitialBackoff time.Duration
+	// Upper bound of the delay, 0 means no bound
+	MaxBackoff time.Duration
+	// Fraction of the delay added to it randomly, spreading out retries of competing processes
+	Jitter float64
+	// Retriable returns whether command failing with given error should be retried
+	Retriable func(error) bool
+}
+
+// Retrying wraps executor to retry commands failing with retriable errors. Waiting between attempts stops
+// when ctx is done.
+func Retrying(executor Executor, policy RetryPolicy) Executor {
+	return func(ctx context.Context, command ...string) (err error) {
+		backoff := policy.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			err = executor(ctx, command...)
+			if err == nil || attempt >= policy.MaxAttempts || policy.Retriable == nil || !policy.Retriable(err) {
+				return
+			}
+
+			delay := policy.jittered(backoff)
+			zap.L().Debug("retrying command",
+				zap.Strings("args", command),
+				zap.Int("attempt", attempt),
+				zap.Duration("delay", delay),
+				zap.Error(err),
+			)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				err = multierr.Append(err, ctx.Err())
+				return
+			case <-timer.C:
+			}
+
+			if backoff *= 2; policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
+
+func (p RetryPolicy) jittered(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return backoff
+	}
+	return backoff + time.Duration(rand.Float64()*p.Jitter*float64(backoff))
+}
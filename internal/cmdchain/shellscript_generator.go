@@ -2,16 +2,27 @@ package cmdchain
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/alessio/shellescape"
 )
 
+const shellIndent = "  "
+
+// ShellScriptGenerator renders executed command chains as POSIX shell. Checks become statements (or
+// `if ! ...; then ...; fi` blocks when negated) guarding the rest of the chain, groups render their children in order.
+// Errors tolerated using CommandChain.WithTolerated are tolerated by the script as well, other interceptors cannot be
+// replicated. Failing commands stop the script only when it runs with `set -e`, like failing commands stop a chain.
+// Generator is safe for concurrent use, chains running concurrently are rendered in the order they start.
 type ShellScriptGenerator struct {
+	mu         sync.Mutex
 	shebang    string
 	shellLines []string
-	stack      []string
+	// Chains already rendered, commands of concurrently running chains may interleave
+	rendered map[*cmdChain]bool
 }
 
 func NewShellScriptGenerator(shebang string) *ShellScriptGenerator {
@@ -21,48 +32,172 @@ func NewShellScriptGenerator(shebang string) *ShellScriptGenerator {
 }
 
 func (s *ShellScriptGenerator) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.shellLines = nil
-	s.stack = nil
+	s.rendered = nil
 }
 
 func (s *ShellScriptGenerator) Executor() Executor {
-	// TODO: group support
 	return func(ctx context.Context, command ...string) error {
-		hasCheck := len(s.stack) > 1
-		self := Self(ctx)
-		parent := Checking(ctx)
-		if parent != nil {
-			s.stack = append(s.stack, "(")
-			if self.Negated() {
-				s.stack = append(s.stack, "!")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		self, ok := Self(ctx).(*cmdChain)
+		if !ok {
+			s.shellLines = append(s.shellLines, shellescape.QuoteCommand(command))
+			return nil
+		}
+
+		// Whole chain is rendered on its first command, rest of its commands are part of it
+		root := self.root()
+		if s.rendered[root] {
+			return nil
+		}
+
+		if s.rendered == nil {
+			s.rendered = map[*cmdChain]bool{}
+		}
+		s.rendered[root] = true
+		s.shellLines = append(s.shellLines, s.render(root, 0, "", false)...)
+		return nil
+	}
+}
+
+// render returns shell lines for a chain. When shortVar is set, it is set to false when
+// chain's command fails with a tolerated error short circuiting the owning chain.
+func (s *ShellScriptGenerator) render(c *cmdChain, depth int, shortVar string, condition bool) (lines []string) {
+	if len(c.args) > 0 {
+		lines = commandLines(c, shortVar, condition)
+	} else {
+		for _, child := range c.children {
+			lines = append(lines, s.render(child.(*cmdChain), depth+1, "", false)...)
+		}
+	}
+
+	if !c.doChecks {
+		return
+	}
+
+	// Checks guard everything after them, thus wrapping from the last one
+	for i := len(c.checks) - 1; i >= 0; i-- {
+		check := c.checks[i].(*cmdChain)
+		if check.negated && len(check.tolerated) == 0 {
+			var wrapped []string
+			if checkLines := s.render(check, depth+1, "", true); len(checkLines) == 1 {
+				wrapped = append(wrapped, "if ! "+checkLines[0]+"; then")
+			} else {
+				wrapped = append(wrapped, "if ! {")
+				wrapped = append(wrapped, indent(checkLines)...)
+				wrapped = append(wrapped, "}; then")
 			}
+			wrapped = append(wrapped, indent(lines)...)
+			lines = append(wrapped, "fi")
+		} else if check.negated || check.shortCircuits() {
+			passVar := fmt.Sprintf("swdfw_pass%d", depth)
+			wrapped := []string{passVar + "=true"}
+			wrapped = append(wrapped, s.render(check, depth+1, passVar, false)...)
+			wrapped = append(wrapped, fmt.Sprintf(`if "$%s"; then`, passVar))
+			wrapped = append(wrapped, indent(lines)...)
+			lines = append(wrapped, "fi")
+		} else {
+			lines = append(s.render(check, depth+1, "", false), lines...)
 		}
+	}
+	return
+}
 
-		if hasCheck {
-			s.stack = append(s.stack, "&&")
+// shortCircuits returns whether chain's command may short circuit the owning chain
+func (c *cmdChain) shortCircuits() bool {
+	for _, t := range c.tolerated {
+		if t.Short {
+			return true
 		}
+	}
+	return false
+}
 
-		s.stack = append(s.stack, shellescape.QuoteCommand(command))
+// commandLines renders chain's command. Tolerated errors are replicated by capturing stderr and matching it
+// along with exit status. In condition mode, lines exit with zero status when command passes.
+// Negated commands short circuit on success and pass on tolerated errors.
+func commandLines(c *cmdChain, shortVar string, condition bool) []string {
+	if len(c.tolerated) == 0 {
+		return []string{commandLine(c)}
+	}
+
+	capture := shellescape.QuoteCommand(c.args) + " 2>&1"
+	if c.stdout == io.Discard {
+		capture = fmt.Sprintf("swdfw_stderr=$(%s >/dev/null)", capture)
+	} else {
+		capture = fmt.Sprintf("{ swdfw_stderr=$(%s 1>&3 3>&-); } 3>&1", capture)
+	}
 
-		stdout, stderr := InputOutput(ctx)
-		if stdout == io.Discard {
-			s.stack = append(s.stack, "1>&-")
+	passPatterns := []string{"0:*"}
+	var shortPatterns []string
+	if c.negated {
+		passPatterns, shortPatterns = nil, passPatterns
+	}
+	for _, t := range c.tolerated {
+		pattern := fmt.Sprintf("%d:*", t.Status)
+		if stderr := strings.TrimSpace(t.Stderr); stderr != "" {
+			pattern += shellescape.Quote(stderr) + "*"
 		}
-		if stderr == io.Discard {
-			s.stack = append(s.stack, "2>&-")
+
+		if t.Short && !condition && !c.negated {
+			shortPatterns = append(shortPatterns, pattern)
+		} else {
+			passPatterns = append(passPatterns, pattern)
 		}
+	}
 
-		if parent == nil {
-			if hasCheck {
-				s.stack = append(s.stack, ")")
-			}
-			s.shellLines = append(s.shellLines, strings.Join(s.stack, " "))
-			s.stack = nil
+	failure := `(exit "$swdfw_status")`
+	if c.stderr != io.Discard {
+		failure = `printf '%s\n' "$swdfw_stderr" >&2; ` + failure
+	}
+
+	lines := []string{
+		"swdfw_status=0",
+		capture + " || swdfw_status=$?",
+		`case "$swdfw_status:$swdfw_stderr" in`,
+	}
+	if condition {
+		lines = append(lines,
+			strings.Join(passPatterns, " | ")+") true ;;",
+			"*) false ;;",
+		)
+	} else {
+		lines = append(lines, strings.Join(passPatterns, " | ")+") ;;")
+		if len(shortPatterns) > 0 && shortVar != "" {
+			lines = append(lines, strings.Join(shortPatterns, " | ")+") "+shortVar+"=false ;;")
 		}
-		return nil
+		if shortVar != "" {
+			failure += "; " + shortVar + "=false"
+		}
+		lines = append(lines, "*) "+failure+" ;;")
+	}
+	return append(lines, "esac")
+}
+
+func commandLine(c *cmdChain) string {
+	line := shellescape.QuoteCommand(c.args)
+	if c.stdout == io.Discard {
+		line += " >/dev/null"
+	}
+	if c.stderr == io.Discard {
+		line += " 2>/dev/null"
+	}
+	return line
+}
+
+func indent(lines []string) (indented []string) {
+	for _, line := range lines {
+		indented = append(indented, shellIndent+line)
 	}
+	return
 }
 
 func (s *ShellScriptGenerator) Script() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return strings.Join(append([]string{s.shebang}, s.shellLines...), "\n") + "\n"
 }
@@ -0,0 +1,142 @@
+package cmdchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+type TraceDecision string
+
+const (
+	// Command succeeded, or failed as expected by a negated chain
+	DecisionPassed TraceDecision = "passed"
+	// Command failed, but error interceptor let the chain pass
+	DecisionTolerated TraceDecision = "tolerated"
+	// Command failed and error interceptor short circuited the chain, or negated chain's command succeeded
+	DecisionShortCircuit TraceDecision = "short-circuit"
+	// Command failed, or negated chain's command failed with an error not tolerated
+	DecisionFailed TraceDecision = "failed"
+)
+
+// TraceEntry describes a single command executed through TraceRecorder
+type TraceEntry struct {
+	Chain string `json:"chain"`
+	// Name of the group chain this chain is a child of
+	Parent string `json:"parent,omitempty"`
+	// Name of the chain this chain is a check of
+	Checking string    `json:"checking,omitempty"`
+	Negated  bool      `json:"negated,omitempty"`
+	Args     []string  `json:"args"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Status   int       `json:"status"`
+	Stderr   string    `json:"stderr,omitempty"`
+	// Error not caused by command exit status, e.g. missing program
+	Error    string        `json:"error,omitempty"`
+	Decision TraceDecision `json:"decision"`
+}
+
+// TraceRecorder records commands executed by the wrapped executor along with their outcome
+type TraceRecorder struct {
+	mu       sync.Mutex
+	executor Executor
+	entries  []TraceEntry
+}
+
+func NewTraceRecorder(executor Executor) *TraceRecorder {
+	return &TraceRecorder{
+		executor: executor,
+	}
+}
+
+func (t *TraceRecorder) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = nil
+}
+
+// Entries returns recorded commands in order they finished
+func (t *TraceRecorder) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEntry{}, t.entries...)
+}
+
+// WriteJSONLines writes recorded commands as JSON lines
+func (t *TraceRecorder) WriteJSONLines(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	for _, entry := range t.Entries() {
+		if err = encoder.Encode(entry); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (t *TraceRecorder) Executor() Executor {
+	return func(ctx context.Context, command ...string) (err error) {
+		entry := TraceEntry{
+			Args: append([]string{}, command...),
+		}
+
+		self, _ := ctx.Value(ContextSelf).(CommandChain)
+		if self != nil {
+			entry.Chain = self.Name()
+			entry.Negated = self.Negated()
+		}
+		if parent, ok := ctx.Value(ContextParent).(CommandChain); ok {
+			entry.Parent = parent.Name()
+		}
+		if checking := Checking(ctx); checking != nil {
+			entry.Checking = checking.Name()
+		}
+
+		// Capture stderr also when chain sends it elsewhere, executors fill ChainExecError otherwise
+		var stderr bytes.Buffer
+		if stdout, chainStderr := InputOutput(ctx); chainStderr != nil {
+			ctx = withInputOutput(ctx, stdout, io.MultiWriter(chainStderr, &stderr))
+		}
+
+		entry.Start = time.Now()
+		err = t.executor(ctx, command...)
+		entry.End = time.Now()
+
+		var cmdErr *ChainExecError
+		if errors.As(err, &cmdErr) {
+			entry.Status = cmdErr.ExitStatus()
+			entry.Stderr = cmdErr.Stderr()
+		} else if err != nil {
+			entry.Error = err.Error()
+		}
+		if stderr.Len() > 0 {
+			entry.Stderr = stderr.String()
+		}
+
+		result := err
+		if c, ok := self.(*cmdChain); ok {
+			// Interceptors are plain functions of the error, thus safe to evaluate here as well
+			result = c.outcome(err)
+		}
+
+		switch {
+		case errors.Is(result, ErrShortCircuit):
+			entry.Decision = DecisionShortCircuit
+		case result != nil:
+			entry.Decision = DecisionFailed
+		case err == nil || entry.Negated:
+			entry.Decision = DecisionPassed
+		default:
+			entry.Decision = DecisionTolerated
+		}
+
+		t.mu.Lock()
+		t.entries = append(t.entries, entry)
+		t.mu.Unlock()
+		return
+	}
+}
@@ -0,0 +1,37 @@
+// Package golden compares test output with golden files kept in testdata directory of the tested package.
+// Running tests with -update flag rewrites golden files with the current output instead.
+package golden
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Assert compares actual output with golden file 'testdata/<name>'
+func Assert(t testing.TB, name string, actual string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden file directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create): %s", err)
+	}
+
+	if string(expected) != actual {
+		t.Errorf("output does not match %s (run with -update to update):\n%s", path, actual)
+	}
+}
@@ -0,0 +1,266 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const cidrAny = "any"
+
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// CIDR is a list of networks matched by a rule, optionally negated. Zero value matches any address.
+// Hostnames are resolved into addresses when rules are applied, see ResolveRules.
+type CIDR struct {
+	Prefixes  []netip.Prefix
+	Hostnames []string
+	Negated   bool
+}
+
+// ParseCIDR parses networks in CIDR notation, bare addresses or hostnames. Each value may contain multiple comma separated
+// entries, 'any' matches any address and '!' prefix negates a single network. Host bits are cleared.
+func ParseCIDR(values ...string) (c CIDR, err error) {
+	var entries []string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "!") {
+			if len(entries) > 1 {
+				err = fmt.Errorf("negated cidr '%s' cannot be combined with other networks", entry)
+				return
+			}
+			c.Negated = true
+			entry = strings.TrimSpace(strings.TrimPrefix(entry, "!"))
+		}
+
+		if strings.EqualFold(entry, cidrAny) {
+			if len(entries) > 1 || c.Negated {
+				err = fmt.Errorf("'%s' cannot be combined with other networks or negated", cidrAny)
+				return
+			}
+			continue
+		}
+
+		if isHostname(entry) {
+			if c.Negated {
+				err = fmt.Errorf("negated hostname '%s' is not supported", entry)
+				return
+			}
+			c.Hostnames = append(c.Hostnames, strings.ToLower(strings.TrimSuffix(entry, ".")))
+			continue
+		}
+
+		var prefix netip.Prefix
+		if prefix, err = parsePrefix(entry); err != nil {
+			return
+		}
+		c.Prefixes = append(c.Prefixes, prefix)
+	}
+
+	c.Prefixes = dedupPrefixes(c.Prefixes)
+	c.Hostnames = dedupStrings(c.Hostnames)
+	return
+}
+
+// isHostname checks whether entry is a valid DNS name, numeric top level labels are rejected to avoid mistaking malformed addresses
+func isHostname(entry string) bool {
+	entry = strings.ToLower(strings.TrimSuffix(entry, "."))
+	if len(entry) == 0 || len(entry) > 253 {
+		return false
+	}
+
+	labels := strings.Split(entry, ".")
+	for _, label := range labels {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
+
+// MustParseCIDR is like ParseCIDR, but panics on invalid input
+func MustParseCIDR(values ...string) CIDR {
+	c, err := ParseCIDR(values...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func parsePrefix(entry string) (prefix netip.Prefix, err error) {
+	if !strings.Contains(entry, "/") {
+		var addr netip.Addr
+		if addr, err = netip.ParseAddr(entry); err != nil {
+			err = fmt.Errorf("invalid cidr '%s': %w", entry, err)
+			return
+		}
+		addr = addr.Unmap()
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+		return
+	}
+
+	if prefix, err = netip.ParsePrefix(entry); err != nil {
+		err = fmt.Errorf("invalid cidr '%s': %w", entry, err)
+		return
+	}
+
+	if prefix.Addr().Is4In6() {
+		err = fmt.Errorf("invalid cidr '%s': ipv4-mapped ipv6 networks are not supported", entry)
+		return
+	}
+	prefix = prefix.Masked()
+	return
+}
+
+func dedupStrings(values []string) (deduped []string) {
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			deduped = append(deduped, value)
+		}
+	}
+	return
+}
+
+func dedupPrefixes(prefixes []netip.Prefix) (deduped []netip.Prefix) {
+	seen := map[netip.Prefix]bool{}
+	for _, prefix := range prefixes {
+		if !seen[prefix] {
+			seen[prefix] = true
+			deduped = append(deduped, prefix)
+		}
+	}
+	return
+}
+
+// IsAny returns whether CIDR matches any address
+func (c CIDR) IsAny() bool {
+	return len(c.Prefixes) == 0 && len(c.Hostnames) == 0
+}
+
+// IsResolved returns whether CIDR contains no hostnames
+func (c CIDR) IsResolved() bool {
+	return len(c.Hostnames) == 0
+}
+
+// HasV4 returns whether CIDR contains any IPv4 networks
+func (c CIDR) HasV4() bool {
+	for _, prefix := range c.Prefixes {
+		if prefix.Addr().Is4() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasV6 returns whether CIDR contains any IPv6 networks
+func (c CIDR) HasV6() bool {
+	for _, prefix := range c.Prefixes {
+		if prefix.Addr().Is6() {
+			return true
+		}
+	}
+	return false
+}
+
+// coversFamily returns whether CIDR may match any addresses of given address family. Negated network matches
+// every address of the other family.
+func (c CIDR) coversFamily(family Protocol) bool {
+	if !c.IsResolved() || c.IsAny() || c.Negated {
+		return true
+	}
+	if family == ProtocolIPv6 {
+		return c.HasV6()
+	}
+	return c.HasV4()
+}
+
+// family returns CIDR containing only networks of given address family, any address is turned into family default route.
+// Negated network of the other family matches any address as well.
+func (c CIDR) family(family Protocol) (filtered CIDR) {
+	if c.Negated && c.IsResolved() && c.HasV6() != (family == ProtocolIPv6) {
+		return CIDR{}.family(family)
+	}
+
+	if c.IsAny() {
+		if family == ProtocolIPv6 {
+			filtered.Prefixes = []netip.Prefix{netip.PrefixFrom(netip.IPv6Unspecified(), 0)}
+		} else {
+			filtered.Prefixes = []netip.Prefix{netip.PrefixFrom(netip.IPv4Unspecified(), 0)}
+		}
+		return
+	}
+
+	filtered.Negated = c.Negated
+	filtered.Hostnames = c.Hostnames
+	for _, prefix := range c.Prefixes {
+		if prefix.Addr().Is6() == (family == ProtocolIPv6) {
+			filtered.Prefixes = append(filtered.Prefixes, prefix)
+		}
+	}
+	return
+}
+
+func (c CIDR) entries() (entries []string) {
+	for _, prefix := range c.Prefixes {
+		entry := prefix.String()
+		if c.Negated {
+			entry = "!" + entry
+		}
+		entries = append(entries, entry)
+	}
+	entries = append(entries, c.Hostnames...)
+	return
+}
+
+func (c CIDR) String() string {
+	if c.IsAny() {
+		return cidrAny
+	}
+	return strings.Join(c.entries(), ",")
+}
+
+func (c CIDR) validate() (err error) {
+	if c.Negated && (len(c.Prefixes) != 1 || len(c.Hostnames) != 0) {
+		err = fmt.Errorf("negated cidr must contain exactly one network")
+	}
+	return
+}
+
+func (c CIDR) MarshalJSON() ([]byte, error) {
+	if len(c.Prefixes)+len(c.Hostnames) <= 1 {
+		return json.Marshal(c.String())
+	}
+	return json.Marshal(c.entries())
+}
+
+func (c *CIDR) UnmarshalJSON(b []byte) (err error) {
+	var values []string
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		err = json.Unmarshal(b, &values)
+	} else {
+		var value string
+		err = json.Unmarshal(b, &value)
+		values = []string{value}
+	}
+	if err != nil {
+		return
+	}
+
+	*c, err = ParseCIDR(values...)
+	return
+}
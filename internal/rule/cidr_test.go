@@ -0,0 +1,330 @@
+package rule_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+func TestCIDRParse(t *testing.T) {
+	cases := map[string]string{
+		"10.123.0.1/24":              "10.123.0.0/24",
+		"10.0.0.1":                   "10.0.0.1/32",
+		"2001:db8::1":                "2001:db8::1/128",
+		"!192.0.2.0/24":              "!192.0.2.0/24",
+		"any":                        "any",
+		"":                           "any",
+		"10.0.0.0/8, 192.0.2.1":      "10.0.0.0/8,192.0.2.1/32",
+		"10.0.0.0/8,10.0.0.1/8":      "10.0.0.0/8",
+		"0.0.0.0/0":                  "0.0.0.0/0",
+		"2001:db8:1::/48,::/0":       "2001:db8:1::/48,::/0",
+		"192.0.2.0/24,2001:db8::/32": "192.0.2.0/24,2001:db8::/32",
+		"Example.COM.,10.0.0.1":      "10.0.0.1/32,example.com",
+	}
+
+	for input, expected := range cases {
+		cidr, err := rule.ParseCIDR(input)
+		if err != nil {
+			t.Errorf("failed to parse '%s': %s", input, err)
+			continue
+		}
+
+		if cidr.String() != expected {
+			t.Errorf("expected '%s' to parse into '%s', got '%s'", input, expected, cidr.String())
+		}
+	}
+
+	invalid := []string{
+		"10.0.0.0/33",
+		"10.0.0.256",
+		"!example.com",
+		"exa_mple.com",
+		"!10.0.0.0/8,192.0.2.0/24",
+		"!any",
+		"any,10.0.0.0/8",
+	}
+
+	for _, input := range invalid {
+		if _, err := rule.ParseCIDR(input); err == nil {
+			t.Errorf("expected '%s' to be invalid", input)
+		}
+	}
+}
+
+func TestCIDRJSON(t *testing.T) {
+	inputs := []string{
+		`"10.0.0.0/8"`,
+		`"!10.0.0.0/8"`,
+		`"any"`,
+		`["10.0.0.0/8","192.0.2.1/32"]`,
+	}
+
+	for _, input := range inputs {
+		var cidr rule.CIDR
+		if err := json.Unmarshal([]byte(input), &cidr); err != nil {
+			t.Errorf("failed to unmarshal '%s': %s", input, err)
+			continue
+		}
+
+		output, err := json.Marshal(cidr)
+		if err != nil {
+			t.Errorf("failed to marshal '%s': %s", input, err)
+			continue
+		}
+
+		if string(output) != input {
+			t.Errorf("expected '%s' to round trip, got '%s'", input, output)
+		}
+	}
+
+	// Legacy 'cidr' field is an alias of 'source'
+	for _, field := range []string{"source", "cidr"} {
+		var r rule.Rule
+		if err := json.Unmarshal([]byte(`{"protocol":"tcp","action":"allow","`+field+`":"10.123.0.1/24"}`), &r); err != nil {
+			t.Fatalf("failed to unmarshal rule: %s", err)
+		}
+
+		if r.Source.String() != "10.123.0.0/24" || !r.CIDR.IsAny() {
+			t.Errorf("unexpected rule source '%s' from '%s'", r.Source.String(), field)
+		}
+	}
+
+	var r rule.Rule
+	if err := json.Unmarshal([]byte(`{"protocol":"tcp","action":"allow","source":"10.0.0.0/8","cidr":"192.0.2.0/24"}`), &r); err == nil {
+		t.Error("expected source combined with legacy cidr field to fail")
+	}
+}
+
+func TestCIDRExpansion(t *testing.T) {
+	multiRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("10.0.0.0/8", "192.0.2.1"),
+		StartPort: 22,
+		Action:    "allow",
+	}
+
+	rulespecs, err := multiRule.ToRulespecs("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rules: %s", err)
+	}
+
+	if len(rulespecs) != 2 {
+		t.Fatalf("expected 2 rulespecs, got %d", len(rulespecs))
+	}
+
+	if joined := strings.Join(rulespecs[1], " "); !strings.HasPrefix(joined, "-s 192.0.2.1/32 -p tcp --dport 22") {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	if _, err = multiRule.ToRulespec("testchain"); err == nil {
+		t.Error("expected single rulespec conversion to fail for multiple networks")
+	}
+
+	negatedRule := rule.Rule{
+		Protocol: "tcp",
+		Source:   rule.MustParseCIDR("!10.0.0.0/8"),
+		Action:   "block",
+	}
+
+	if _, err = negatedRule.ToRulespec("testchain"); err == nil {
+		t.Error("expected single rulespec conversion to fail for negated network covering other family")
+	}
+
+	negatedRules, err := negatedRule.SplitFamilies()
+	if err != nil {
+		t.Fatalf("failed to split rule: %s", err)
+	}
+
+	rulespec, err := negatedRules[0].ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	if joined := strings.Join(rulespec, " "); !strings.HasPrefix(joined, "! -s 10.0.0.0/8 -p tcp") {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	anyRule := rule.Rule{
+		Protocol: "udp",
+		Source:   rule.MustParseCIDR("any"),
+		Action:   "allow",
+	}
+
+	if _, err = anyRule.ToRulespec("testchain"); err == nil {
+		t.Error("expected single rulespec conversion to fail for dual-stack rule")
+	}
+}
+
+func TestDualStackRules(t *testing.T) {
+	cases := []struct {
+		rule     rule.Rule
+		expected []string
+	}{
+		{
+			rule: rule.Rule{
+				Protocol: "udp",
+				Action:   "allow",
+			},
+			expected: []string{
+				"-s 0.0.0.0/0 -p udp -j RETURN",
+				"-s ::/0 -p udp -j RETURN",
+			},
+		},
+		{
+			rule: rule.Rule{
+				Protocol: "tcp",
+				Source:   rule.MustParseCIDR("10.0.0.0/8", "2001:db8::/32"),
+				Action:   "block",
+			},
+			expected: []string{
+				"-s 10.0.0.0/8 -p tcp -j REJECT --reject-with icmp-port-unreachable",
+				"-s 2001:db8::/32 -p tcp -j REJECT --reject-with icmp6-port-unreachable",
+			},
+		},
+		{
+			rule: rule.Rule{
+				Protocol: "tcp",
+				Source:   rule.MustParseCIDR("!10.0.0.0/8"),
+				Action:   "block",
+			},
+			expected: []string{
+				"! -s 10.0.0.0/8 -p tcp -j REJECT --reject-with icmp-port-unreachable",
+				"-s ::/0 -p tcp -j REJECT --reject-with icmp6-port-unreachable",
+			},
+		},
+		{
+			rule: rule.Rule{
+				Protocol:    "udp",
+				Destination: rule.MustParseCIDR("!2001:db8::/32"),
+				Action:      "block",
+			},
+			expected: []string{
+				"-s 0.0.0.0/0 -d 0.0.0.0/0 -p udp -j REJECT --reject-with icmp-port-unreachable",
+				"-s ::/0 ! -d 2001:db8::/32 -p udp -j REJECT --reject-with icmp6-port-unreachable",
+			},
+		},
+		{
+			rule: rule.Rule{
+				Protocol: "icmp",
+				Action:   "allow",
+			},
+			expected: []string{
+				"-s 0.0.0.0/0 -p icmp -j RETURN",
+				"-s ::/0 -p icmpv6 -j RETURN",
+			},
+		},
+		{
+			rule: rule.Rule{
+				Protocol: "icmp",
+				Action:   "allow",
+				Flags:    []string{"icmp:echo-request"},
+			},
+			expected: []string{
+				"-s 0.0.0.0/0 -p icmp --icmp-type 8 -j RETURN",
+			},
+		},
+		{
+			rule: rule.Rule{
+				Protocol: "tcpv6",
+				Action:   "allow",
+			},
+			expected: []string{
+				"-s ::/0 -p tcp -j RETURN",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		familyRules, err := c.rule.SplitFamilies()
+		if err != nil {
+			t.Fatalf("failed to split rule: %s", err)
+		}
+
+		if len(familyRules) != len(c.expected) {
+			t.Errorf("expected %d family rules, got %d", len(c.expected), len(familyRules))
+			continue
+		}
+
+		for i, familyRule := range familyRules {
+			rulespec, err := familyRule.ToRulespec("testchain")
+			if err != nil {
+				t.Fatalf("failed to create rule: %s", err)
+			}
+
+			if joined := strings.Join(rulespec, " "); !strings.HasPrefix(joined, c.expected[i]) {
+				t.Errorf("expected rulespec to start with '%s', got '%s'", c.expected[i], joined)
+			}
+		}
+	}
+
+	legacyMixedRule := rule.Rule{
+		Protocol: "tcpv6",
+		Source:   rule.MustParseCIDR("10.0.0.0/8", "2001:db8::/32"),
+		Action:   "allow",
+	}
+
+	if err := legacyMixedRule.Validate(); err == nil {
+		t.Error("expected ipv6 rule containing ipv4 network to be invalid")
+	}
+}
+
+func TestRuleDestination(t *testing.T) {
+	httpsRule := rule.Rule{
+		Protocol:    "tcp",
+		Destination: rule.MustParseCIDR("203.0.113.5"),
+		StartPort:   443,
+		Action:      "allow",
+	}
+
+	familyRules, err := httpsRule.SplitFamilies()
+	if err != nil {
+		t.Fatalf("failed to split rule: %s", err)
+	}
+
+	if len(familyRules) != 1 {
+		t.Fatalf("expected rule with ipv4 destination to be ipv4 only, got %d family rules", len(familyRules))
+	}
+
+	rulespec, err := familyRules[0].ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	if joined := strings.Join(rulespec, " "); !strings.HasPrefix(joined, "-s 0.0.0.0/0 -d 203.0.113.5/32 -p tcp --dport 443") {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	outputRule := rule.Rule{
+		Protocol:    "udp",
+		Direction:   "output",
+		Source:      rule.MustParseCIDR("10.0.0.1", "10.0.0.2"),
+		Destination: rule.MustParseCIDR("!192.0.2.0/24"),
+		Action:      "block",
+	}
+
+	rulespecs, err := outputRule.ToRulespecs("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rules: %s", err)
+	}
+
+	if len(rulespecs) != 2 {
+		t.Fatalf("expected 2 rulespecs, got %d", len(rulespecs))
+	}
+
+	if joined := strings.Join(rulespecs[1], " "); !strings.HasPrefix(joined, "-s 10.0.0.2/32 ! -d 192.0.2.0/24 -p udp") {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	mismatchedRule := rule.Rule{
+		Protocol:    "tcp",
+		Source:      rule.MustParseCIDR("10.0.0.0/8"),
+		Destination: rule.MustParseCIDR("2001:db8::1"),
+		Action:      "allow",
+	}
+
+	if err = mismatchedRule.Validate(); err == nil {
+		t.Error("expected rule with mismatching source and destination families to be invalid")
+	}
+}
@@ -0,0 +1,89 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// iptables comment match allows up to 255 bytes
+	commentLimit = 255
+	idLimit      = 64
+)
+
+var (
+	validID        = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+	commentPattern = regexp.MustCompile(`^Autogenerated rule using swdfw from '([^']*)'(?: id '([^']*)')?(?:: (.*))?$`)
+)
+
+func validateID(id string) (err error) {
+	if id == "" {
+		return
+	}
+
+	if len(id) > idLimit {
+		err = fmt.Errorf("rule id '%s' too long (%d > %d)", id, len(id), idLimit)
+	} else if !validID.MatchString(id) {
+		err = fmt.Errorf("rule id '%s' contains invalid characters", id)
+	}
+	return
+}
+
+// validateComment rejects double quotes, nftables comments are double quoted strings without a way to escape them
+func validateComment(comment string) (err error) {
+	if strings.Contains(comment, `"`) {
+		err = fmt.Errorf("rule comment must not contain double quotes")
+	}
+	return
+}
+
+// sanitizeComment replaces non-printable characters (newlines etc.) with spaces
+func sanitizeComment(comment string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return ' '
+		}
+		return r
+	}, comment))
+}
+
+// truncateUTF8 cuts string to at most limit bytes without splitting multibyte characters
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// FormatComment builds iptables comment identifying the chain and rule it was generated from, see ParseComment
+func FormatComment(chainName, id, comment string) string {
+	s := fmt.Sprintf("Autogenerated rule using swdfw from '%s'", chainName)
+	if id != "" {
+		s += fmt.Sprintf(" id '%s'", id)
+	}
+
+	if comment = sanitizeComment(comment); comment != "" {
+		s += ": " + comment
+	}
+	return truncateUTF8(s, commentLimit)
+}
+
+// ParseComment extracts chain name, rule id and user comment from a comment built by FormatComment
+func ParseComment(s string) (chainName, id, comment string, ok bool) {
+	m := commentPattern.FindStringSubmatch(s)
+	if m == nil {
+		return
+	}
+	return m[1], m[2], m[3], true
+}
+
+func (r *Rule) commentspec(chainName string) []string {
+	return []string{"-m", "comment", "--comment", FormatComment(chainName, r.ID, r.Comment)}
+}
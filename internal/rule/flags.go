@@ -1,5 +1,12 @@
 package rule
 
+import "strings"
+
+var (
+	statePrefix   = "state:"
+	tcpFlagPrefix = "tcp:"
+)
+
 var (
 	validTCPFlags = map[string]bool{
 		"tcp:ack":  true,
@@ -11,16 +18,54 @@ var (
 		"tcp:syn":  true,
 		"tcp:urg":  true,
 	}
-	validICMPTypes = map[string]bool{
-		// TODO: iptables -p icmp -h
-	}
-	validICMPV6Types = map[string]bool{
-		// TODO: ip6tables -p icmpv6 -h
-	}
 	validStates = map[string]bool{
 		"state:established": true,
 		"state:invalid":     true,
 		"state:new":         true,
 		"state:related":     true,
 	}
+	// tcpFlagNames lists tcp flags in iptables order, 'all' and 'none' referring to all of them
+	tcpFlagNames = []string{"fin", "syn", "rst", "psh", "ack", "urg"}
 )
+
+// tcpFlags returns tcp flags examined (mask) and ones of them required to be set (comp).
+// Listed flags need to be set, 'none' and 'all' require all flags to be unset or set respectively.
+func (r *Rule) tcpFlags() (mask, comp []string) {
+	listed := flagSet(r.flagValues(tcpFlagPrefix))
+	switch {
+	case len(listed) == 0:
+	case listed["none"]:
+		mask = tcpFlagNames
+	case listed["all"]:
+		mask, comp = tcpFlagNames, tcpFlagNames
+	default:
+		for _, name := range tcpFlagNames {
+			if listed[name] {
+				mask = append(mask, name)
+			}
+		}
+		comp = mask
+	}
+	return
+}
+
+func (r *Rule) tcpFlagsMatchspec() []string {
+	mask, comp := r.tcpFlags()
+	if mask == nil {
+		return nil
+	}
+
+	compValue := "NONE"
+	if len(comp) > 0 {
+		compValue = strings.ToUpper(strings.Join(comp, ","))
+	}
+	return []string{"--tcp-flags", strings.ToUpper(strings.Join(mask, ",")), compValue}
+}
+
+// tcpOption returns tcp option kind which needs to be present
+func (r *Rule) tcpOption() (kind string, ok bool) {
+	if values := r.flagValues(tcpOptPrefix); len(values) > 0 {
+		kind, ok = values[0], true
+	}
+	return
+}
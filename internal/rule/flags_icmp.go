@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	// Names accepted by iptables in addition to IANA registry names
+	icmpTypeAliases = map[string]string{
+		(icmpTypePrefix + "any"):                        "any",
+		(icmpTypePrefix + "echo-request"):               "8",
+		(icmpTypePrefix + "ping"):                       "8",
+		(icmpTypePrefix + "pong"):                       "0",
+		(icmpTypePrefix + "network-unreachable"):        "3/0",
+		(icmpTypePrefix + "host-unreachable"):           "3/1",
+		(icmpTypePrefix + "protocol-unreachable"):       "3/2",
+		(icmpTypePrefix + "port-unreachable"):           "3/3",
+		(icmpTypePrefix + "fragmentation-needed"):       "3/4",
+		(icmpTypePrefix + "source-route-failed"):        "3/5",
+		(icmpTypePrefix + "network-unknown"):            "3/6",
+		(icmpTypePrefix + "host-unknown"):               "3/7",
+		(icmpTypePrefix + "network-prohibited"):         "3/9",
+		(icmpTypePrefix + "host-prohibited"):            "3/10",
+		(icmpTypePrefix + "tos-network-unreachable"):    "3/11",
+		(icmpTypePrefix + "tos-host-unreachable"):       "3/12",
+		(icmpTypePrefix + "communication-prohibited"):   "3/13",
+		(icmpTypePrefix + "host-precedence-violation"):  "3/14",
+		(icmpTypePrefix + "precedence-cutoff"):          "3/15",
+		(icmpTypePrefix + "network-redirect"):           "5/0",
+		(icmpTypePrefix + "host-redirect"):              "5/1",
+		(icmpTypePrefix + "tos-network-redirect"):       "5/2",
+		(icmpTypePrefix + "tos-host-redirect"):          "5/3",
+		(icmpTypePrefix + "ttl-exceeded"):               "11",
+		(icmpTypePrefix + "ttl-zero-during-transit"):    "11/0",
+		(icmpTypePrefix + "ttl-zero-during-reassembly"): "11/1",
+		(icmpTypePrefix + "ip-header-bad"):              "12/0",
+		(icmpTypePrefix + "required-option-missing"):    "12/1",
+		(icmpTypePrefix + "timestamp-request"):          "13",
+	}
+	icmpv6TypeAliases = map[string]string{
+		(icmpv6TypePrefix + "no-route"):                   "1/0",
+		(icmpv6TypePrefix + "communication-prohibited"):   "1/1",
+		(icmpv6TypePrefix + "beyond-scope"):               "1/2",
+		(icmpv6TypePrefix + "address-unreachable"):        "1/3",
+		(icmpv6TypePrefix + "port-unreachable"):           "1/4",
+		(icmpv6TypePrefix + "failed-policy"):              "1/5",
+		(icmpv6TypePrefix + "reject-route"):               "1/6",
+		(icmpv6TypePrefix + "ttl-exceeded"):               "3",
+		(icmpv6TypePrefix + "ttl-zero-during-transit"):    "3/0",
+		(icmpv6TypePrefix + "ttl-zero-during-reassembly"): "3/1",
+		(icmpv6TypePrefix + "bad-header"):                 "4/0",
+		(icmpv6TypePrefix + "unknown-header-type"):        "4/1",
+		(icmpv6TypePrefix + "unknown-option"):             "4/2",
+		(icmpv6TypePrefix + "ping"):                       "128",
+		(icmpv6TypePrefix + "pong"):                       "129",
+		(icmpv6TypePrefix + "mld-listener-query"):         "130",
+		(icmpv6TypePrefix + "mld-listener-report"):        "131",
+		(icmpv6TypePrefix + "mld-listener-done"):          "132",
+		(icmpv6TypePrefix + "mld-listener-reduction"):     "132",
+		(icmpv6TypePrefix + "neighbour-solicitation"):     "135",
+		(icmpv6TypePrefix + "neighbour-advertisement"):    "136",
+		(icmpv6TypePrefix + "redirect"):                   "137",
+	}
+
+	// Types required for IPv6 neighbor discovery (RFC 4861) to work
+	icmpv6NeighborDiscoveryTypes = []string{
+		"router-solicitation",
+		"router-advertisement",
+		"neighbor-solicitation",
+		"neighbor-advertisement",
+	}
+)
+
+// resolveICMPType turns 'icmp:<name>', 'icmp:<type>' or 'icmp:<type>/<code>' flag (or icmpv6 equivalent)
+// into iptables --icmp-type / --icmpv6-type value
+func resolveICMPType(flag string) (value string, ok bool) {
+	prefix, names, aliases, types := icmpTypePrefix, icmpTypeNames, icmpTypeAliases, validICMPTypes
+	if strings.HasPrefix(flag, icmpv6TypePrefix) {
+		prefix, names, aliases, types = icmpv6TypePrefix, icmpv6TypeNames, icmpv6TypeAliases, validICMPV6Types
+	} else if !strings.HasPrefix(flag, icmpTypePrefix) {
+		return
+	}
+
+	if value, ok = names[flag]; ok {
+		return
+	}
+
+	if value, ok = aliases[flag]; ok {
+		return
+	}
+
+	typ, code, hasCode := strings.Cut(flag, "/")
+	if ok = types[typ]; !ok {
+		return
+	}
+
+	value = strings.TrimPrefix(typ, prefix)
+	if hasCode {
+		parsedCode, err := strconv.ParseUint(code, 10, 8)
+		if err != nil {
+			return "", false
+		}
+		value += "/" + strconv.FormatUint(parsedCode, 10)
+	}
+	return
+}
+
+func (r *Rule) icmpType() (value string, ok bool) {
+	for _, flag := range r.Flags {
+		if value, ok = resolveICMPType(flag); ok {
+			return
+		}
+	}
+	return
+}
+
+// ICMPv6NeighborDiscoveryRules returns rules allowing ICMPv6 neighbor discovery messages, without which IPv6 cannot function.
+func ICMPv6NeighborDiscoveryRules() (rules []Rule) {
+	for _, typ := range icmpv6NeighborDiscoveryTypes {
+		rules = append(rules, Rule{
+			Protocol: "icmpv6",
+			Source:   MustParseCIDR("::/0"),
+			Action:   "allow",
+			Flags:    []string{icmpv6TypePrefix + typ},
+		})
+	}
+	return
+}
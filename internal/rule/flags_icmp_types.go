@@ -0,0 +1,596 @@
+package rule
+
+//go:generate go run ../../hack/parse_icmp_params.go
+
+var icmpTypePrefix = "icmp:"
+var icmpv6TypePrefix = "icmpv6:"
+
+var validICMPTypes = map[string]bool{
+	(icmpTypePrefix + "0"):   true, // Echo Reply
+	(icmpTypePrefix + "1"):   true, // Unassigned
+	(icmpTypePrefix + "2"):   true, // Unassigned
+	(icmpTypePrefix + "3"):   true, // Destination Unreachable
+	(icmpTypePrefix + "4"):   true, // Source Quench (Deprecated)
+	(icmpTypePrefix + "5"):   true, // Redirect
+	(icmpTypePrefix + "6"):   true, // Alternate Host Address (Deprecated)
+	(icmpTypePrefix + "7"):   true, // Unassigned
+	(icmpTypePrefix + "8"):   true, // Echo
+	(icmpTypePrefix + "9"):   true, // Router Advertisement
+	(icmpTypePrefix + "10"):  true, // Router Solicitation
+	(icmpTypePrefix + "11"):  true, // Time Exceeded
+	(icmpTypePrefix + "12"):  true, // Parameter Problem
+	(icmpTypePrefix + "13"):  true, // Timestamp
+	(icmpTypePrefix + "14"):  true, // Timestamp Reply
+	(icmpTypePrefix + "15"):  true, // Information Request (Deprecated)
+	(icmpTypePrefix + "16"):  true, // Information Reply (Deprecated)
+	(icmpTypePrefix + "17"):  true, // Address Mask Request (Deprecated)
+	(icmpTypePrefix + "18"):  true, // Address Mask Reply (Deprecated)
+	(icmpTypePrefix + "19"):  true, // Reserved (for Security)
+	(icmpTypePrefix + "20"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "21"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "22"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "23"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "24"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "25"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "26"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "27"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "28"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "29"):  true, // Reserved (for Robustness Experiment)
+	(icmpTypePrefix + "30"):  true, // Traceroute (Deprecated)
+	(icmpTypePrefix + "31"):  true, // Datagram Conversion Error (Deprecated)
+	(icmpTypePrefix + "32"):  true, // Mobile Host Redirect (Deprecated)
+	(icmpTypePrefix + "33"):  true, // IPv6 Where-Are-You (Deprecated)
+	(icmpTypePrefix + "34"):  true, // IPv6 I-Am-Here (Deprecated)
+	(icmpTypePrefix + "35"):  true, // Mobile Registration Request (Deprecated)
+	(icmpTypePrefix + "36"):  true, // Mobile Registration Reply (Deprecated)
+	(icmpTypePrefix + "37"):  true, // Domain Name Request (Deprecated)
+	(icmpTypePrefix + "38"):  true, // Domain Name Reply (Deprecated)
+	(icmpTypePrefix + "39"):  true, // SKIP (Deprecated)
+	(icmpTypePrefix + "40"):  true, // Photuris
+	(icmpTypePrefix + "41"):  true, // ICMP messages utilized by experimental mobility protocols such as Seamoby
+	(icmpTypePrefix + "42"):  true, // Extended Echo Request
+	(icmpTypePrefix + "43"):  true, // Extended Echo Reply
+	(icmpTypePrefix + "44"):  true, // Unassigned
+	(icmpTypePrefix + "45"):  true, // Unassigned
+	(icmpTypePrefix + "46"):  true, // Unassigned
+	(icmpTypePrefix + "47"):  true, // Unassigned
+	(icmpTypePrefix + "48"):  true, // Unassigned
+	(icmpTypePrefix + "49"):  true, // Unassigned
+	(icmpTypePrefix + "50"):  true, // Unassigned
+	(icmpTypePrefix + "51"):  true, // Unassigned
+	(icmpTypePrefix + "52"):  true, // Unassigned
+	(icmpTypePrefix + "53"):  true, // Unassigned
+	(icmpTypePrefix + "54"):  true, // Unassigned
+	(icmpTypePrefix + "55"):  true, // Unassigned
+	(icmpTypePrefix + "56"):  true, // Unassigned
+	(icmpTypePrefix + "57"):  true, // Unassigned
+	(icmpTypePrefix + "58"):  true, // Unassigned
+	(icmpTypePrefix + "59"):  true, // Unassigned
+	(icmpTypePrefix + "60"):  true, // Unassigned
+	(icmpTypePrefix + "61"):  true, // Unassigned
+	(icmpTypePrefix + "62"):  true, // Unassigned
+	(icmpTypePrefix + "63"):  true, // Unassigned
+	(icmpTypePrefix + "64"):  true, // Unassigned
+	(icmpTypePrefix + "65"):  true, // Unassigned
+	(icmpTypePrefix + "66"):  true, // Unassigned
+	(icmpTypePrefix + "67"):  true, // Unassigned
+	(icmpTypePrefix + "68"):  true, // Unassigned
+	(icmpTypePrefix + "69"):  true, // Unassigned
+	(icmpTypePrefix + "70"):  true, // Unassigned
+	(icmpTypePrefix + "71"):  true, // Unassigned
+	(icmpTypePrefix + "72"):  true, // Unassigned
+	(icmpTypePrefix + "73"):  true, // Unassigned
+	(icmpTypePrefix + "74"):  true, // Unassigned
+	(icmpTypePrefix + "75"):  true, // Unassigned
+	(icmpTypePrefix + "76"):  true, // Unassigned
+	(icmpTypePrefix + "77"):  true, // Unassigned
+	(icmpTypePrefix + "78"):  true, // Unassigned
+	(icmpTypePrefix + "79"):  true, // Unassigned
+	(icmpTypePrefix + "80"):  true, // Unassigned
+	(icmpTypePrefix + "81"):  true, // Unassigned
+	(icmpTypePrefix + "82"):  true, // Unassigned
+	(icmpTypePrefix + "83"):  true, // Unassigned
+	(icmpTypePrefix + "84"):  true, // Unassigned
+	(icmpTypePrefix + "85"):  true, // Unassigned
+	(icmpTypePrefix + "86"):  true, // Unassigned
+	(icmpTypePrefix + "87"):  true, // Unassigned
+	(icmpTypePrefix + "88"):  true, // Unassigned
+	(icmpTypePrefix + "89"):  true, // Unassigned
+	(icmpTypePrefix + "90"):  true, // Unassigned
+	(icmpTypePrefix + "91"):  true, // Unassigned
+	(icmpTypePrefix + "92"):  true, // Unassigned
+	(icmpTypePrefix + "93"):  true, // Unassigned
+	(icmpTypePrefix + "94"):  true, // Unassigned
+	(icmpTypePrefix + "95"):  true, // Unassigned
+	(icmpTypePrefix + "96"):  true, // Unassigned
+	(icmpTypePrefix + "97"):  true, // Unassigned
+	(icmpTypePrefix + "98"):  true, // Unassigned
+	(icmpTypePrefix + "99"):  true, // Unassigned
+	(icmpTypePrefix + "100"): true, // Unassigned
+	(icmpTypePrefix + "101"): true, // Unassigned
+	(icmpTypePrefix + "102"): true, // Unassigned
+	(icmpTypePrefix + "103"): true, // Unassigned
+	(icmpTypePrefix + "104"): true, // Unassigned
+	(icmpTypePrefix + "105"): true, // Unassigned
+	(icmpTypePrefix + "106"): true, // Unassigned
+	(icmpTypePrefix + "107"): true, // Unassigned
+	(icmpTypePrefix + "108"): true, // Unassigned
+	(icmpTypePrefix + "109"): true, // Unassigned
+	(icmpTypePrefix + "110"): true, // Unassigned
+	(icmpTypePrefix + "111"): true, // Unassigned
+	(icmpTypePrefix + "112"): true, // Unassigned
+	(icmpTypePrefix + "113"): true, // Unassigned
+	(icmpTypePrefix + "114"): true, // Unassigned
+	(icmpTypePrefix + "115"): true, // Unassigned
+	(icmpTypePrefix + "116"): true, // Unassigned
+	(icmpTypePrefix + "117"): true, // Unassigned
+	(icmpTypePrefix + "118"): true, // Unassigned
+	(icmpTypePrefix + "119"): true, // Unassigned
+	(icmpTypePrefix + "120"): true, // Unassigned
+	(icmpTypePrefix + "121"): true, // Unassigned
+	(icmpTypePrefix + "122"): true, // Unassigned
+	(icmpTypePrefix + "123"): true, // Unassigned
+	(icmpTypePrefix + "124"): true, // Unassigned
+	(icmpTypePrefix + "125"): true, // Unassigned
+	(icmpTypePrefix + "126"): true, // Unassigned
+	(icmpTypePrefix + "127"): true, // Unassigned
+	(icmpTypePrefix + "128"): true, // Unassigned
+	(icmpTypePrefix + "129"): true, // Unassigned
+	(icmpTypePrefix + "130"): true, // Unassigned
+	(icmpTypePrefix + "131"): true, // Unassigned
+	(icmpTypePrefix + "132"): true, // Unassigned
+	(icmpTypePrefix + "133"): true, // Unassigned
+	(icmpTypePrefix + "134"): true, // Unassigned
+	(icmpTypePrefix + "135"): true, // Unassigned
+	(icmpTypePrefix + "136"): true, // Unassigned
+	(icmpTypePrefix + "137"): true, // Unassigned
+	(icmpTypePrefix + "138"): true, // Unassigned
+	(icmpTypePrefix + "139"): true, // Unassigned
+	(icmpTypePrefix + "140"): true, // Unassigned
+	(icmpTypePrefix + "141"): true, // Unassigned
+	(icmpTypePrefix + "142"): true, // Unassigned
+	(icmpTypePrefix + "143"): true, // Unassigned
+	(icmpTypePrefix + "144"): true, // Unassigned
+	(icmpTypePrefix + "145"): true, // Unassigned
+	(icmpTypePrefix + "146"): true, // Unassigned
+	(icmpTypePrefix + "147"): true, // Unassigned
+	(icmpTypePrefix + "148"): true, // Unassigned
+	(icmpTypePrefix + "149"): true, // Unassigned
+	(icmpTypePrefix + "150"): true, // Unassigned
+	(icmpTypePrefix + "151"): true, // Unassigned
+	(icmpTypePrefix + "152"): true, // Unassigned
+	(icmpTypePrefix + "153"): true, // Unassigned
+	(icmpTypePrefix + "154"): true, // Unassigned
+	(icmpTypePrefix + "155"): true, // Unassigned
+	(icmpTypePrefix + "156"): true, // Unassigned
+	(icmpTypePrefix + "157"): true, // Unassigned
+	(icmpTypePrefix + "158"): true, // Unassigned
+	(icmpTypePrefix + "159"): true, // Unassigned
+	(icmpTypePrefix + "160"): true, // Unassigned
+	(icmpTypePrefix + "161"): true, // Unassigned
+	(icmpTypePrefix + "162"): true, // Unassigned
+	(icmpTypePrefix + "163"): true, // Unassigned
+	(icmpTypePrefix + "164"): true, // Unassigned
+	(icmpTypePrefix + "165"): true, // Unassigned
+	(icmpTypePrefix + "166"): true, // Unassigned
+	(icmpTypePrefix + "167"): true, // Unassigned
+	(icmpTypePrefix + "168"): true, // Unassigned
+	(icmpTypePrefix + "169"): true, // Unassigned
+	(icmpTypePrefix + "170"): true, // Unassigned
+	(icmpTypePrefix + "171"): true, // Unassigned
+	(icmpTypePrefix + "172"): true, // Unassigned
+	(icmpTypePrefix + "173"): true, // Unassigned
+	(icmpTypePrefix + "174"): true, // Unassigned
+	(icmpTypePrefix + "175"): true, // Unassigned
+	(icmpTypePrefix + "176"): true, // Unassigned
+	(icmpTypePrefix + "177"): true, // Unassigned
+	(icmpTypePrefix + "178"): true, // Unassigned
+	(icmpTypePrefix + "179"): true, // Unassigned
+	(icmpTypePrefix + "180"): true, // Unassigned
+	(icmpTypePrefix + "181"): true, // Unassigned
+	(icmpTypePrefix + "182"): true, // Unassigned
+	(icmpTypePrefix + "183"): true, // Unassigned
+	(icmpTypePrefix + "184"): true, // Unassigned
+	(icmpTypePrefix + "185"): true, // Unassigned
+	(icmpTypePrefix + "186"): true, // Unassigned
+	(icmpTypePrefix + "187"): true, // Unassigned
+	(icmpTypePrefix + "188"): true, // Unassigned
+	(icmpTypePrefix + "189"): true, // Unassigned
+	(icmpTypePrefix + "190"): true, // Unassigned
+	(icmpTypePrefix + "191"): true, // Unassigned
+	(icmpTypePrefix + "192"): true, // Unassigned
+	(icmpTypePrefix + "193"): true, // Unassigned
+	(icmpTypePrefix + "194"): true, // Unassigned
+	(icmpTypePrefix + "195"): true, // Unassigned
+	(icmpTypePrefix + "196"): true, // Unassigned
+	(icmpTypePrefix + "197"): true, // Unassigned
+	(icmpTypePrefix + "198"): true, // Unassigned
+	(icmpTypePrefix + "199"): true, // Unassigned
+	(icmpTypePrefix + "200"): true, // Unassigned
+	(icmpTypePrefix + "201"): true, // Unassigned
+	(icmpTypePrefix + "202"): true, // Unassigned
+	(icmpTypePrefix + "203"): true, // Unassigned
+	(icmpTypePrefix + "204"): true, // Unassigned
+	(icmpTypePrefix + "205"): true, // Unassigned
+	(icmpTypePrefix + "206"): true, // Unassigned
+	(icmpTypePrefix + "207"): true, // Unassigned
+	(icmpTypePrefix + "208"): true, // Unassigned
+	(icmpTypePrefix + "209"): true, // Unassigned
+	(icmpTypePrefix + "210"): true, // Unassigned
+	(icmpTypePrefix + "211"): true, // Unassigned
+	(icmpTypePrefix + "212"): true, // Unassigned
+	(icmpTypePrefix + "213"): true, // Unassigned
+	(icmpTypePrefix + "214"): true, // Unassigned
+	(icmpTypePrefix + "215"): true, // Unassigned
+	(icmpTypePrefix + "216"): true, // Unassigned
+	(icmpTypePrefix + "217"): true, // Unassigned
+	(icmpTypePrefix + "218"): true, // Unassigned
+	(icmpTypePrefix + "219"): true, // Unassigned
+	(icmpTypePrefix + "220"): true, // Unassigned
+	(icmpTypePrefix + "221"): true, // Unassigned
+	(icmpTypePrefix + "222"): true, // Unassigned
+	(icmpTypePrefix + "223"): true, // Unassigned
+	(icmpTypePrefix + "224"): true, // Unassigned
+	(icmpTypePrefix + "225"): true, // Unassigned
+	(icmpTypePrefix + "226"): true, // Unassigned
+	(icmpTypePrefix + "227"): true, // Unassigned
+	(icmpTypePrefix + "228"): true, // Unassigned
+	(icmpTypePrefix + "229"): true, // Unassigned
+	(icmpTypePrefix + "230"): true, // Unassigned
+	(icmpTypePrefix + "231"): true, // Unassigned
+	(icmpTypePrefix + "232"): true, // Unassigned
+	(icmpTypePrefix + "233"): true, // Unassigned
+	(icmpTypePrefix + "234"): true, // Unassigned
+	(icmpTypePrefix + "235"): true, // Unassigned
+	(icmpTypePrefix + "236"): true, // Unassigned
+	(icmpTypePrefix + "237"): true, // Unassigned
+	(icmpTypePrefix + "238"): true, // Unassigned
+	(icmpTypePrefix + "239"): true, // Unassigned
+	(icmpTypePrefix + "240"): true, // Unassigned
+	(icmpTypePrefix + "241"): true, // Unassigned
+	(icmpTypePrefix + "242"): true, // Unassigned
+	(icmpTypePrefix + "243"): true, // Unassigned
+	(icmpTypePrefix + "244"): true, // Unassigned
+	(icmpTypePrefix + "245"): true, // Unassigned
+	(icmpTypePrefix + "246"): true, // Unassigned
+	(icmpTypePrefix + "247"): true, // Unassigned
+	(icmpTypePrefix + "248"): true, // Unassigned
+	(icmpTypePrefix + "249"): true, // Unassigned
+	(icmpTypePrefix + "250"): true, // Unassigned
+	(icmpTypePrefix + "251"): true, // Unassigned
+	(icmpTypePrefix + "252"): true, // Unassigned
+	(icmpTypePrefix + "253"): true, // RFC3692-style Experiment 1
+	(icmpTypePrefix + "254"): true, // RFC3692-style Experiment 2
+	(icmpTypePrefix + "255"): true, // Reserved
+}
+
+var icmpTypeNames = map[string]string{
+	(icmpTypePrefix + "echo-reply"):                  "0",
+	(icmpTypePrefix + "destination-unreachable"):     "3",
+	(icmpTypePrefix + "source-quench"):               "4",
+	(icmpTypePrefix + "redirect"):                    "5",
+	(icmpTypePrefix + "alternate-host-address"):      "6",
+	(icmpTypePrefix + "echo"):                        "8",
+	(icmpTypePrefix + "router-advertisement"):        "9",
+	(icmpTypePrefix + "router-solicitation"):         "10",
+	(icmpTypePrefix + "time-exceeded"):               "11",
+	(icmpTypePrefix + "parameter-problem"):           "12",
+	(icmpTypePrefix + "timestamp"):                   "13",
+	(icmpTypePrefix + "timestamp-reply"):             "14",
+	(icmpTypePrefix + "information-request"):         "15",
+	(icmpTypePrefix + "information-reply"):           "16",
+	(icmpTypePrefix + "address-mask-request"):        "17",
+	(icmpTypePrefix + "address-mask-reply"):          "18",
+	(icmpTypePrefix + "traceroute"):                  "30",
+	(icmpTypePrefix + "datagram-conversion-error"):   "31",
+	(icmpTypePrefix + "mobile-host-redirect"):        "32",
+	(icmpTypePrefix + "ipv6-where-are-you"):          "33",
+	(icmpTypePrefix + "ipv6-i-am-here"):              "34",
+	(icmpTypePrefix + "mobile-registration-request"): "35",
+	(icmpTypePrefix + "mobile-registration-reply"):   "36",
+	(icmpTypePrefix + "domain-name-request"):         "37",
+	(icmpTypePrefix + "domain-name-reply"):           "38",
+	(icmpTypePrefix + "skip"):                        "39",
+	(icmpTypePrefix + "photuris"):                    "40",
+	(icmpTypePrefix + "extended-echo-request"):       "42",
+	(icmpTypePrefix + "extended-echo-reply"):         "43",
+}
+
+var validICMPV6Types = map[string]bool{
+	(icmpv6TypePrefix + "0"):   true, // Reserved
+	(icmpv6TypePrefix + "1"):   true, // Destination Unreachable
+	(icmpv6TypePrefix + "2"):   true, // Packet Too Big
+	(icmpv6TypePrefix + "3"):   true, // Time Exceeded
+	(icmpv6TypePrefix + "4"):   true, // Parameter Problem
+	(icmpv6TypePrefix + "5"):   true, // Unassigned
+	(icmpv6TypePrefix + "6"):   true, // Unassigned
+	(icmpv6TypePrefix + "7"):   true, // Unassigned
+	(icmpv6TypePrefix + "8"):   true, // Unassigned
+	(icmpv6TypePrefix + "9"):   true, // Unassigned
+	(icmpv6TypePrefix + "10"):  true, // Unassigned
+	(icmpv6TypePrefix + "11"):  true, // Unassigned
+	(icmpv6TypePrefix + "12"):  true, // Unassigned
+	(icmpv6TypePrefix + "13"):  true, // Unassigned
+	(icmpv6TypePrefix + "14"):  true, // Unassigned
+	(icmpv6TypePrefix + "15"):  true, // Unassigned
+	(icmpv6TypePrefix + "16"):  true, // Unassigned
+	(icmpv6TypePrefix + "17"):  true, // Unassigned
+	(icmpv6TypePrefix + "18"):  true, // Unassigned
+	(icmpv6TypePrefix + "19"):  true, // Unassigned
+	(icmpv6TypePrefix + "20"):  true, // Unassigned
+	(icmpv6TypePrefix + "21"):  true, // Unassigned
+	(icmpv6TypePrefix + "22"):  true, // Unassigned
+	(icmpv6TypePrefix + "23"):  true, // Unassigned
+	(icmpv6TypePrefix + "24"):  true, // Unassigned
+	(icmpv6TypePrefix + "25"):  true, // Unassigned
+	(icmpv6TypePrefix + "26"):  true, // Unassigned
+	(icmpv6TypePrefix + "27"):  true, // Unassigned
+	(icmpv6TypePrefix + "28"):  true, // Unassigned
+	(icmpv6TypePrefix + "29"):  true, // Unassigned
+	(icmpv6TypePrefix + "30"):  true, // Unassigned
+	(icmpv6TypePrefix + "31"):  true, // Unassigned
+	(icmpv6TypePrefix + "32"):  true, // Unassigned
+	(icmpv6TypePrefix + "33"):  true, // Unassigned
+	(icmpv6TypePrefix + "34"):  true, // Unassigned
+	(icmpv6TypePrefix + "35"):  true, // Unassigned
+	(icmpv6TypePrefix + "36"):  true, // Unassigned
+	(icmpv6TypePrefix + "37"):  true, // Unassigned
+	(icmpv6TypePrefix + "38"):  true, // Unassigned
+	(icmpv6TypePrefix + "39"):  true, // Unassigned
+	(icmpv6TypePrefix + "40"):  true, // Unassigned
+	(icmpv6TypePrefix + "41"):  true, // Unassigned
+	(icmpv6TypePrefix + "42"):  true, // Unassigned
+	(icmpv6TypePrefix + "43"):  true, // Unassigned
+	(icmpv6TypePrefix + "44"):  true, // Unassigned
+	(icmpv6TypePrefix + "45"):  true, // Unassigned
+	(icmpv6TypePrefix + "46"):  true, // Unassigned
+	(icmpv6TypePrefix + "47"):  true, // Unassigned
+	(icmpv6TypePrefix + "48"):  true, // Unassigned
+	(icmpv6TypePrefix + "49"):  true, // Unassigned
+	(icmpv6TypePrefix + "50"):  true, // Unassigned
+	(icmpv6TypePrefix + "51"):  true, // Unassigned
+	(icmpv6TypePrefix + "52"):  true, // Unassigned
+	(icmpv6TypePrefix + "53"):  true, // Unassigned
+	(icmpv6TypePrefix + "54"):  true, // Unassigned
+	(icmpv6TypePrefix + "55"):  true, // Unassigned
+	(icmpv6TypePrefix + "56"):  true, // Unassigned
+	(icmpv6TypePrefix + "57"):  true, // Unassigned
+	(icmpv6TypePrefix + "58"):  true, // Unassigned
+	(icmpv6TypePrefix + "59"):  true, // Unassigned
+	(icmpv6TypePrefix + "60"):  true, // Unassigned
+	(icmpv6TypePrefix + "61"):  true, // Unassigned
+	(icmpv6TypePrefix + "62"):  true, // Unassigned
+	(icmpv6TypePrefix + "63"):  true, // Unassigned
+	(icmpv6TypePrefix + "64"):  true, // Unassigned
+	(icmpv6TypePrefix + "65"):  true, // Unassigned
+	(icmpv6TypePrefix + "66"):  true, // Unassigned
+	(icmpv6TypePrefix + "67"):  true, // Unassigned
+	(icmpv6TypePrefix + "68"):  true, // Unassigned
+	(icmpv6TypePrefix + "69"):  true, // Unassigned
+	(icmpv6TypePrefix + "70"):  true, // Unassigned
+	(icmpv6TypePrefix + "71"):  true, // Unassigned
+	(icmpv6TypePrefix + "72"):  true, // Unassigned
+	(icmpv6TypePrefix + "73"):  true, // Unassigned
+	(icmpv6TypePrefix + "74"):  true, // Unassigned
+	(icmpv6TypePrefix + "75"):  true, // Unassigned
+	(icmpv6TypePrefix + "76"):  true, // Unassigned
+	(icmpv6TypePrefix + "77"):  true, // Unassigned
+	(icmpv6TypePrefix + "78"):  true, // Unassigned
+	(icmpv6TypePrefix + "79"):  true, // Unassigned
+	(icmpv6TypePrefix + "80"):  true, // Unassigned
+	(icmpv6TypePrefix + "81"):  true, // Unassigned
+	(icmpv6TypePrefix + "82"):  true, // Unassigned
+	(icmpv6TypePrefix + "83"):  true, // Unassigned
+	(icmpv6TypePrefix + "84"):  true, // Unassigned
+	(icmpv6TypePrefix + "85"):  true, // Unassigned
+	(icmpv6TypePrefix + "86"):  true, // Unassigned
+	(icmpv6TypePrefix + "87"):  true, // Unassigned
+	(icmpv6TypePrefix + "88"):  true, // Unassigned
+	(icmpv6TypePrefix + "89"):  true, // Unassigned
+	(icmpv6TypePrefix + "90"):  true, // Unassigned
+	(icmpv6TypePrefix + "91"):  true, // Unassigned
+	(icmpv6TypePrefix + "92"):  true, // Unassigned
+	(icmpv6TypePrefix + "93"):  true, // Unassigned
+	(icmpv6TypePrefix + "94"):  true, // Unassigned
+	(icmpv6TypePrefix + "95"):  true, // Unassigned
+	(icmpv6TypePrefix + "96"):  true, // Unassigned
+	(icmpv6TypePrefix + "97"):  true, // Unassigned
+	(icmpv6TypePrefix + "98"):  true, // Unassigned
+	(icmpv6TypePrefix + "99"):  true, // Unassigned
+	(icmpv6TypePrefix + "100"): true, // Private experimentation
+	(icmpv6TypePrefix + "101"): true, // Private experimentation
+	(icmpv6TypePrefix + "102"): true, // Unassigned
+	(icmpv6TypePrefix + "103"): true, // Unassigned
+	(icmpv6TypePrefix + "104"): true, // Unassigned
+	(icmpv6TypePrefix + "105"): true, // Unassigned
+	(icmpv6TypePrefix + "106"): true, // Unassigned
+	(icmpv6TypePrefix + "107"): true, // Unassigned
+	(icmpv6TypePrefix + "108"): true, // Unassigned
+	(icmpv6TypePrefix + "109"): true, // Unassigned
+	(icmpv6TypePrefix + "110"): true, // Unassigned
+	(icmpv6TypePrefix + "111"): true, // Unassigned
+	(icmpv6TypePrefix + "112"): true, // Unassigned
+	(icmpv6TypePrefix + "113"): true, // Unassigned
+	(icmpv6TypePrefix + "114"): true, // Unassigned
+	(icmpv6TypePrefix + "115"): true, // Unassigned
+	(icmpv6TypePrefix + "116"): true, // Unassigned
+	(icmpv6TypePrefix + "117"): true, // Unassigned
+	(icmpv6TypePrefix + "118"): true, // Unassigned
+	(icmpv6TypePrefix + "119"): true, // Unassigned
+	(icmpv6TypePrefix + "120"): true, // Unassigned
+	(icmpv6TypePrefix + "121"): true, // Unassigned
+	(icmpv6TypePrefix + "122"): true, // Unassigned
+	(icmpv6TypePrefix + "123"): true, // Unassigned
+	(icmpv6TypePrefix + "124"): true, // Unassigned
+	(icmpv6TypePrefix + "125"): true, // Unassigned
+	(icmpv6TypePrefix + "126"): true, // Unassigned
+	(icmpv6TypePrefix + "127"): true, // Reserved for expansion of ICMPv6 error messages
+	(icmpv6TypePrefix + "128"): true, // Echo Request
+	(icmpv6TypePrefix + "129"): true, // Echo Reply
+	(icmpv6TypePrefix + "130"): true, // Multicast Listener Query
+	(icmpv6TypePrefix + "131"): true, // Multicast Listener Report
+	(icmpv6TypePrefix + "132"): true, // Multicast Listener Done
+	(icmpv6TypePrefix + "133"): true, // Router Solicitation
+	(icmpv6TypePrefix + "134"): true, // Router Advertisement
+	(icmpv6TypePrefix + "135"): true, // Neighbor Solicitation
+	(icmpv6TypePrefix + "136"): true, // Neighbor Advertisement
+	(icmpv6TypePrefix + "137"): true, // Redirect Message
+	(icmpv6TypePrefix + "138"): true, // Router Renumbering
+	(icmpv6TypePrefix + "139"): true, // ICMP Node Information Query
+	(icmpv6TypePrefix + "140"): true, // ICMP Node Information Response
+	(icmpv6TypePrefix + "141"): true, // Inverse Neighbor Discovery Solicitation Message
+	(icmpv6TypePrefix + "142"): true, // Inverse Neighbor Discovery Advertisement Message
+	(icmpv6TypePrefix + "143"): true, // Version 2 Multicast Listener Report
+	(icmpv6TypePrefix + "144"): true, // Home Agent Address Discovery Request Message
+	(icmpv6TypePrefix + "145"): true, // Home Agent Address Discovery Reply Message
+	(icmpv6TypePrefix + "146"): true, // Mobile Prefix Solicitation
+	(icmpv6TypePrefix + "147"): true, // Mobile Prefix Advertisement
+	(icmpv6TypePrefix + "148"): true, // Certification Path Solicitation Message
+	(icmpv6TypePrefix + "149"): true, // Certification Path Advertisement Message
+	(icmpv6TypePrefix + "150"): true, // ICMP messages utilized by experimental mobility protocols such as Seamoby
+	(icmpv6TypePrefix + "151"): true, // Multicast Router Advertisement
+	(icmpv6TypePrefix + "152"): true, // Multicast Router Solicitation
+	(icmpv6TypePrefix + "153"): true, // Multicast Router Termination
+	(icmpv6TypePrefix + "154"): true, // FMIPv6 Messages
+	(icmpv6TypePrefix + "155"): true, // RPL Control Message
+	(icmpv6TypePrefix + "156"): true, // ILNPv6 Locator Update Message
+	(icmpv6TypePrefix + "157"): true, // Duplicate Address Request
+	(icmpv6TypePrefix + "158"): true, // Duplicate Address Confirmation
+	(icmpv6TypePrefix + "159"): true, // MPL Control Message
+	(icmpv6TypePrefix + "160"): true, // Extended Echo Request
+	(icmpv6TypePrefix + "161"): true, // Extended Echo Reply
+	(icmpv6TypePrefix + "162"): true, // Unassigned
+	(icmpv6TypePrefix + "163"): true, // Unassigned
+	(icmpv6TypePrefix + "164"): true, // Unassigned
+	(icmpv6TypePrefix + "165"): true, // Unassigned
+	(icmpv6TypePrefix + "166"): true, // Unassigned
+	(icmpv6TypePrefix + "167"): true, // Unassigned
+	(icmpv6TypePrefix + "168"): true, // Unassigned
+	(icmpv6TypePrefix + "169"): true, // Unassigned
+	(icmpv6TypePrefix + "170"): true, // Unassigned
+	(icmpv6TypePrefix + "171"): true, // Unassigned
+	(icmpv6TypePrefix + "172"): true, // Unassigned
+	(icmpv6TypePrefix + "173"): true, // Unassigned
+	(icmpv6TypePrefix + "174"): true, // Unassigned
+	(icmpv6TypePrefix + "175"): true, // Unassigned
+	(icmpv6TypePrefix + "176"): true, // Unassigned
+	(icmpv6TypePrefix + "177"): true, // Unassigned
+	(icmpv6TypePrefix + "178"): true, // Unassigned
+	(icmpv6TypePrefix + "179"): true, // Unassigned
+	(icmpv6TypePrefix + "180"): true, // Unassigned
+	(icmpv6TypePrefix + "181"): true, // Unassigned
+	(icmpv6TypePrefix + "182"): true, // Unassigned
+	(icmpv6TypePrefix + "183"): true, // Unassigned
+	(icmpv6TypePrefix + "184"): true, // Unassigned
+	(icmpv6TypePrefix + "185"): true, // Unassigned
+	(icmpv6TypePrefix + "186"): true, // Unassigned
+	(icmpv6TypePrefix + "187"): true, // Unassigned
+	(icmpv6TypePrefix + "188"): true, // Unassigned
+	(icmpv6TypePrefix + "189"): true, // Unassigned
+	(icmpv6TypePrefix + "190"): true, // Unassigned
+	(icmpv6TypePrefix + "191"): true, // Unassigned
+	(icmpv6TypePrefix + "192"): true, // Unassigned
+	(icmpv6TypePrefix + "193"): true, // Unassigned
+	(icmpv6TypePrefix + "194"): true, // Unassigned
+	(icmpv6TypePrefix + "195"): true, // Unassigned
+	(icmpv6TypePrefix + "196"): true, // Unassigned
+	(icmpv6TypePrefix + "197"): true, // Unassigned
+	(icmpv6TypePrefix + "198"): true, // Unassigned
+	(icmpv6TypePrefix + "199"): true, // Unassigned
+	(icmpv6TypePrefix + "200"): true, // Private experimentation
+	(icmpv6TypePrefix + "201"): true, // Private experimentation
+	(icmpv6TypePrefix + "202"): true, // Unassigned
+	(icmpv6TypePrefix + "203"): true, // Unassigned
+	(icmpv6TypePrefix + "204"): true, // Unassigned
+	(icmpv6TypePrefix + "205"): true, // Unassigned
+	(icmpv6TypePrefix + "206"): true, // Unassigned
+	(icmpv6TypePrefix + "207"): true, // Unassigned
+	(icmpv6TypePrefix + "208"): true, // Unassigned
+	(icmpv6TypePrefix + "209"): true, // Unassigned
+	(icmpv6TypePrefix + "210"): true, // Unassigned
+	(icmpv6TypePrefix + "211"): true, // Unassigned
+	(icmpv6TypePrefix + "212"): true, // Unassigned
+	(icmpv6TypePrefix + "213"): true, // Unassigned
+	(icmpv6TypePrefix + "214"): true, // Unassigned
+	(icmpv6TypePrefix + "215"): true, // Unassigned
+	(icmpv6TypePrefix + "216"): true, // Unassigned
+	(icmpv6TypePrefix + "217"): true, // Unassigned
+	(icmpv6TypePrefix + "218"): true, // Unassigned
+	(icmpv6TypePrefix + "219"): true, // Unassigned
+	(icmpv6TypePrefix + "220"): true, // Unassigned
+	(icmpv6TypePrefix + "221"): true, // Unassigned
+	(icmpv6TypePrefix + "222"): true, // Unassigned
+	(icmpv6TypePrefix + "223"): true, // Unassigned
+	(icmpv6TypePrefix + "224"): true, // Unassigned
+	(icmpv6TypePrefix + "225"): true, // Unassigned
+	(icmpv6TypePrefix + "226"): true, // Unassigned
+	(icmpv6TypePrefix + "227"): true, // Unassigned
+	(icmpv6TypePrefix + "228"): true, // Unassigned
+	(icmpv6TypePrefix + "229"): true, // Unassigned
+	(icmpv6TypePrefix + "230"): true, // Unassigned
+	(icmpv6TypePrefix + "231"): true, // Unassigned
+	(icmpv6TypePrefix + "232"): true, // Unassigned
+	(icmpv6TypePrefix + "233"): true, // Unassigned
+	(icmpv6TypePrefix + "234"): true, // Unassigned
+	(icmpv6TypePrefix + "235"): true, // Unassigned
+	(icmpv6TypePrefix + "236"): true, // Unassigned
+	(icmpv6TypePrefix + "237"): true, // Unassigned
+	(icmpv6TypePrefix + "238"): true, // Unassigned
+	(icmpv6TypePrefix + "239"): true, // Unassigned
+	(icmpv6TypePrefix + "240"): true, // Unassigned
+	(icmpv6TypePrefix + "241"): true, // Unassigned
+	(icmpv6TypePrefix + "242"): true, // Unassigned
+	(icmpv6TypePrefix + "243"): true, // Unassigned
+	(icmpv6TypePrefix + "244"): true, // Unassigned
+	(icmpv6TypePrefix + "245"): true, // Unassigned
+	(icmpv6TypePrefix + "246"): true, // Unassigned
+	(icmpv6TypePrefix + "247"): true, // Unassigned
+	(icmpv6TypePrefix + "248"): true, // Unassigned
+	(icmpv6TypePrefix + "249"): true, // Unassigned
+	(icmpv6TypePrefix + "250"): true, // Unassigned
+	(icmpv6TypePrefix + "251"): true, // Unassigned
+	(icmpv6TypePrefix + "252"): true, // Unassigned
+	(icmpv6TypePrefix + "253"): true, // Unassigned
+	(icmpv6TypePrefix + "254"): true, // Unassigned
+	(icmpv6TypePrefix + "255"): true, // Reserved for expansion of ICMPv6 informational messages
+}
+
+var icmpv6TypeNames = map[string]string{
+	(icmpv6TypePrefix + "destination-unreachable"):                          "1",
+	(icmpv6TypePrefix + "packet-too-big"):                                   "2",
+	(icmpv6TypePrefix + "time-exceeded"):                                    "3",
+	(icmpv6TypePrefix + "parameter-problem"):                                "4",
+	(icmpv6TypePrefix + "echo-request"):                                     "128",
+	(icmpv6TypePrefix + "echo-reply"):                                       "129",
+	(icmpv6TypePrefix + "multicast-listener-query"):                         "130",
+	(icmpv6TypePrefix + "multicast-listener-report"):                        "131",
+	(icmpv6TypePrefix + "multicast-listener-done"):                          "132",
+	(icmpv6TypePrefix + "router-solicitation"):                              "133",
+	(icmpv6TypePrefix + "router-advertisement"):                             "134",
+	(icmpv6TypePrefix + "neighbor-solicitation"):                            "135",
+	(icmpv6TypePrefix + "neighbor-advertisement"):                           "136",
+	(icmpv6TypePrefix + "redirect-message"):                                 "137",
+	(icmpv6TypePrefix + "router-renumbering"):                               "138",
+	(icmpv6TypePrefix + "icmp-node-information-query"):                      "139",
+	(icmpv6TypePrefix + "icmp-node-information-response"):                   "140",
+	(icmpv6TypePrefix + "inverse-neighbor-discovery-solicitation-message"):  "141",
+	(icmpv6TypePrefix + "inverse-neighbor-discovery-advertisement-message"): "142",
+	(icmpv6TypePrefix + "version-2-multicast-listener-report"):              "143",
+	(icmpv6TypePrefix + "home-agent-address-discovery-request-message"):     "144",
+	(icmpv6TypePrefix + "home-agent-address-discovery-reply-message"):       "145",
+	(icmpv6TypePrefix + "mobile-prefix-solicitation"):                       "146",
+	(icmpv6TypePrefix + "mobile-prefix-advertisement"):                      "147",
+	(icmpv6TypePrefix + "certification-path-solicitation-message"):          "148",
+	(icmpv6TypePrefix + "certification-path-advertisement-message"):         "149",
+	(icmpv6TypePrefix + "multicast-router-advertisement"):                   "151",
+	(icmpv6TypePrefix + "multicast-router-solicitation"):                    "152",
+	(icmpv6TypePrefix + "multicast-router-termination"):                     "153",
+	(icmpv6TypePrefix + "fmipv6-messages"):                                  "154",
+	(icmpv6TypePrefix + "rpl-control-message"):                              "155",
+	(icmpv6TypePrefix + "ilnpv6-locator-update-message"):                    "156",
+	(icmpv6TypePrefix + "duplicate-address-request"):                        "157",
+	(icmpv6TypePrefix + "duplicate-address-confirmation"):                   "158",
+	(icmpv6TypePrefix + "mpl-control-message"):                              "159",
+	(icmpv6TypePrefix + "extended-echo-request"):                            "160",
+	(icmpv6TypePrefix + "extended-echo-reply"):                              "161",
+}
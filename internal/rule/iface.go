@@ -0,0 +1,53 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Interface names accepted by the kernel are shorter than IFNAMSIZ (16). Wildcards ('+') are not supported,
+// as nftables spells them differently
+var interfacePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,15}$`)
+
+func validateInterface(name string) (err error) {
+	if !interfacePattern.MatchString(name) || name == "." || name == ".." {
+		err = fmt.Errorf("invalid interface name '%s'", name)
+	}
+	return
+}
+
+// validateInterfaces checks interfaces are only matched in directions iptables allows them in
+func (r *Rule) validateInterfaces() (err error) {
+	if r.SourceInterface != "" {
+		if r.Direction == "output" {
+			err = fmt.Errorf("source interface cannot be matched in output rules")
+			return
+		}
+
+		if err = validateInterface(r.SourceInterface); err != nil {
+			return
+		}
+	}
+
+	if r.DestinationInterface != "" {
+		if r.Direction == "input" {
+			err = fmt.Errorf("destination interface cannot be matched in input rules")
+			return
+		}
+
+		if err = validateInterface(r.DestinationInterface); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *Rule) interfaceMatchspec() (s []string) {
+	if r.SourceInterface != "" {
+		s = append(s, "-i", r.SourceInterface)
+	}
+	if r.DestinationInterface != "" {
+		s = append(s, "-o", r.DestinationInterface)
+	}
+	return
+}
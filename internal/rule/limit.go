@@ -0,0 +1,109 @@
+package rule
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+const defaultRateBurst = 5
+
+// RateLimit makes rule match only while packets arrive below given rate, either globally or per source address.
+// Corresponds to iptables `limit` / `hashlimit --hashlimit-upto` matches and nftables `limit rate` / meters.
+type RateLimit struct {
+	Rate      string `json:"rate"`
+	Burst     uint32 `json:"burst"`
+	PerSource bool   `json:"per_source"`
+	// Source address prefix length used to group sources, 0 means full address. Only with PerSource
+	SourceMask uint8 `json:"source_mask"`
+}
+
+// ConnLimit makes rule match only when amount of concurrent connections from a source network is above given limit.
+// Corresponds to iptables `connlimit --connlimit-above` and nftables `ct count over`.
+// Only supported on block rules, which then block connections exceeding the limit. An allow rule would allow
+// connections only once the limit is exceeded, so it is rejected.
+type ConnLimit struct {
+	Above uint32 `json:"above"`
+	// Source address prefix length used to group connections, 0 means full address
+	Mask uint8 `json:"mask"`
+}
+
+func maxMask(v6 bool) uint8 {
+	if v6 {
+		return 128
+	}
+	return 32
+}
+
+func (l *RateLimit) Validate(v6 bool) (err error) {
+	if l.Rate, err = normalizeRate(l.Rate); err != nil {
+		return
+	}
+
+	if l.Burst == 0 {
+		l.Burst = defaultRateBurst
+	}
+
+	if !l.PerSource && l.SourceMask != 0 {
+		err = fmt.Errorf("rate limit source mask is supported only with per source rate limit")
+		return
+	}
+
+	if l.SourceMask > maxMask(v6) {
+		err = fmt.Errorf("rate limit source mask /%d is out of range", l.SourceMask)
+	}
+	return
+}
+
+func (l *ConnLimit) Validate(v6 bool) (err error) {
+	if l.Above == 0 {
+		err = fmt.Errorf("connection limit must be positive")
+		return
+	}
+
+	if l.Mask > maxMask(v6) {
+		err = fmt.Errorf("connection limit mask /%d is out of range", l.Mask)
+	}
+	return
+}
+
+// effectiveMask returns mask to use for given address family, 0 meaning full address
+func effectiveMask(mask uint8, v6 bool) uint8 {
+	if mask == 0 {
+		return maxMask(v6)
+	}
+	return mask
+}
+
+func (l *RateLimit) toMatchspec(hashlimitName string, v6 bool) []string {
+	burst := strconv.FormatUint(uint64(l.Burst), 10)
+	if !l.PerSource {
+		return []string{"-m", "limit", "--limit", l.Rate, "--limit-burst", burst}
+	}
+
+	return []string{
+		"-m", "hashlimit",
+		"--hashlimit-upto", l.Rate,
+		"--hashlimit-burst", burst,
+		"--hashlimit-mode", "srcip",
+		"--hashlimit-srcmask", strconv.Itoa(int(effectiveMask(l.SourceMask, v6))),
+		"--hashlimit-name", hashlimitName,
+	}
+}
+
+func (l *ConnLimit) toMatchspec(v6 bool) []string {
+	return []string{
+		"-m", "connlimit",
+		"--connlimit-above", strconv.FormatUint(uint64(l.Above), 10),
+		"--connlimit-mask", strconv.Itoa(int(effectiveMask(l.Mask, v6))),
+		"--connlimit-saddr",
+	}
+}
+
+// hashlimitName derives a short stable hashlimit table name, kernel limits it to 15 characters on older versions
+func hashlimitName(parts ...string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join(parts, "\x00")))
+	return fmt.Sprintf("swdfw%08x", h.Sum32())
+}
@@ -0,0 +1,147 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// iptables LOG target accepts prefix up to 29 characters
+	logPrefixLimit = 29
+	// iptables NFLOG target accepts prefix up to 63 characters
+	nflogPrefixLimit = 63
+
+	defaultLogLimit = "5/minute"
+	defaultLogBurst = 5
+)
+
+var (
+	supportedLogTargets = map[string]bool{
+		"log":   true,
+		"nflog": true,
+	}
+	supportedLogLevels = map[string]bool{
+		"emerg":   true,
+		"alert":   true,
+		"crit":    true,
+		"error":   true,
+		"warning": true,
+		"notice":  true,
+		"info":    true,
+		"debug":   true,
+	}
+	rateUnits = map[string]string{
+		"s":      "second",
+		"sec":    "second",
+		"second": "second",
+		"m":      "minute",
+		"min":    "minute",
+		"minute": "minute",
+		"h":      "hour",
+		"hour":   "hour",
+		"d":      "day",
+		"day":    "day",
+	}
+)
+
+// Log describes a rate-limited LOG or NFLOG rule inserted ahead of rule action
+type Log struct {
+	Target string `json:"target"`
+	Prefix string `json:"prefix"`
+	Level  string `json:"level"` // Only LOG
+	Group  uint16 `json:"group"` // Only NFLOG
+	Limit  string `json:"limit"`
+	Burst  uint32 `json:"burst"`
+}
+
+func (l *Log) Validate() (err error) {
+	if l.Target, err = normalizeValue("log target", l.Target, "log", supportedLogTargets); err != nil {
+		return
+	}
+
+	if l.Limit == "" {
+		l.Limit = defaultLogLimit
+	}
+	if l.Limit, err = normalizeRate(l.Limit); err != nil {
+		return
+	}
+
+	if l.Burst == 0 {
+		l.Burst = defaultLogBurst
+	}
+
+	if l.Target == "log" {
+		if l.Level, err = normalizeValue("log level", l.Level, "warning", supportedLogLevels); err != nil {
+			return
+		}
+		if l.Group != 0 {
+			err = fmt.Errorf("log group is supported only with nflog target")
+			return
+		}
+	} else if l.Level != "" {
+		err = fmt.Errorf("log level is supported only with log target")
+		return
+	}
+
+	if limit := l.prefixLimit(); len(l.Prefix) > limit {
+		err = fmt.Errorf("log prefix '%s' too long (%d > %d)", l.Prefix, len(l.Prefix), limit)
+	}
+	return
+}
+
+func (l *Log) prefixLimit() int {
+	if l.Target == "nflog" {
+		return nflogPrefixLimit
+	}
+	return logPrefixLimit
+}
+
+// derivePrefix builds log prefix out of chain name and rule index, shortening the chain name when needed
+func (l *Log) derivePrefix(chainName string, index int) string {
+	suffix := fmt.Sprintf(":%d ", index)
+	if over := len(chainName) + len(suffix) - l.prefixLimit(); over > 0 {
+		chainName = chainName[:len(chainName)-over]
+	}
+	return chainName + suffix
+}
+
+func (l *Log) toTargetspec(chainName string, index int) (s []string) {
+	prefix := l.Prefix
+	if prefix == "" {
+		prefix = l.derivePrefix(chainName, index)
+	}
+
+	s = []string{"-m", "limit", "--limit", l.Limit, "--limit-burst", strconv.FormatUint(uint64(l.Burst), 10)}
+	switch l.Target {
+	case "log":
+		s = append(s, "-j", "LOG", "--log-prefix", prefix, "--log-level", l.Level)
+	case "nflog":
+		s = append(s, "-j", "NFLOG", "--nflog-group", strconv.Itoa(int(l.Group)), "--nflog-prefix", prefix)
+	}
+	return
+}
+
+// normalizeRate validates rate in form of '<n>/<unit>' and expands the unit to full name
+func normalizeRate(rate string) (normalized string, err error) {
+	split := strings.SplitN(strings.ToLower(rate), "/", 2)
+	if len(split) != 2 {
+		err = fmt.Errorf("invalid rate '%s', expected '<n>/<unit>'", rate)
+		return
+	}
+
+	var n uint64
+	if n, err = strconv.ParseUint(split[0], 10, 32); err != nil || n == 0 {
+		err = fmt.Errorf("invalid rate '%s', expected positive amount", rate)
+		return
+	}
+
+	unit, ok := rateUnits[split[1]]
+	if !ok {
+		err = fmt.Errorf("invalid rate '%s', unsupported unit '%s'", rate, split[1])
+		return
+	}
+
+	normalized = fmt.Sprintf("%d/%s", n, unit)
+	return
+}
@@ -0,0 +1,41 @@
+package rule
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// normalizeMAC validates EUI-48 address optionally prefixed with '!' and formats it as lowercase colon separated
+func normalizeMAC(v string) (normalized string, err error) {
+	v = strings.TrimSpace(v)
+	negated := false
+	if strings.HasPrefix(v, "!") {
+		negated = true
+		v = strings.TrimSpace(strings.TrimPrefix(v, "!"))
+	}
+
+	var hw net.HardwareAddr
+	if hw, err = net.ParseMAC(v); err != nil {
+		err = fmt.Errorf("invalid mac address '%s': %w", v, err)
+		return
+	}
+
+	if len(hw) != 6 {
+		err = fmt.Errorf("invalid mac address '%s': only 48-bit addresses are supported", v)
+		return
+	}
+
+	normalized = hw.String()
+	if negated {
+		normalized = "!" + normalized
+	}
+	return
+}
+
+func macMatchspec(mac string) []string {
+	if strings.HasPrefix(mac, "!") {
+		return []string{"-m", "mac", "!", "--mac-source", strings.TrimPrefix(mac, "!")}
+	}
+	return []string{"-m", "mac", "--mac-source", mac}
+}
@@ -0,0 +1,284 @@
+package rule
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Covers returns whether this rule matches every packet the other rule matches, ignoring actions.
+// The check is conservative: false is returned when coverage cannot be determined, e.g. for rate limited rules
+// or rules with unresolved hostnames.
+func (r *Rule) Covers(other *Rule) bool {
+	rules, err := r.SplitFamilies()
+	if err != nil {
+		return false
+	}
+
+	otherRules, err := other.SplitFamilies()
+	if err != nil {
+		return false
+	}
+
+	for i := range otherRules {
+		covered := false
+		for j := range rules {
+			if rules[j].Proto() == otherRules[i].Proto() && rules[j].coversSingle(&otherRules[i]) {
+				covered = true
+				break
+			}
+		}
+
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// coversSingle compares two single family rules
+func (r *Rule) coversSingle(other *Rule) bool {
+	if !r.Source.IsResolved() || !r.Destination.IsResolved() || !other.Source.IsResolved() || !other.Destination.IsResolved() {
+		return false
+	}
+
+	// Rate and connection limited rules do not match every packet
+	if r.RateLimit != nil || r.ConnLimit != nil {
+		return false
+	}
+
+	if r.Direction != other.Direction {
+		return false
+	}
+
+	if r.ProtocolName() != "all" && r.ProtocolName() != other.ProtocolName() {
+		return false
+	}
+
+	if !r.Source.covers(other.Source) || !r.Destination.covers(other.Destination) {
+		return false
+	}
+
+	if !r.Ports.covers(other.Ports) || !r.sourcePorts().covers(other.sourcePorts()) || !r.coversFlags(other) {
+		return false
+	}
+
+	for _, match := range [][2]string{
+		{r.SourceMAC, other.SourceMAC},
+		{r.SourceInterface, other.SourceInterface},
+		{r.DestinationInterface, other.DestinationInterface},
+	} {
+		if match[0] != "" && match[0] != match[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) coversFlags(other *Rule) bool {
+	// Any of listed states match, so other rule states need to be a subset
+	states := r.flagValues(statePrefix)
+	if len(states) > 0 {
+		otherStates := flagSet(other.flagValues(statePrefix))
+		if len(otherStates) == 0 {
+			return false
+		}
+
+		stateSet := flagSet(states)
+		for state := range otherStates {
+			if !stateSet[state] {
+				return false
+			}
+		}
+	}
+
+	// Rest of the flags all need to match, so these need to be present in other rule as well
+	otherFlags := flagSet(other.Flags)
+	for _, flag := range r.Flags {
+		if !strings.HasPrefix(flag, statePrefix) && !otherFlags[flag] {
+			return false
+		}
+	}
+	return true
+}
+
+func flagSet(flags []string) (set map[string]bool) {
+	set = map[string]bool{}
+	for _, flag := range flags {
+		set[flag] = true
+	}
+	return
+}
+
+func isDefaultRoute(prefix netip.Prefix) bool {
+	return prefix.Bits() == 0
+}
+
+// covers returns whether every address matched by other is matched by c, both being resolved and of single family
+func (c CIDR) covers(other CIDR) bool {
+	if c.IsAny() {
+		return true
+	}
+
+	if other.IsAny() {
+		return !c.Negated && len(c.Prefixes) == 1 && isDefaultRoute(c.Prefixes[0])
+	}
+
+	switch {
+	case !c.Negated && !other.Negated:
+		for _, otherPrefix := range other.Prefixes {
+			if !prefixesContain(c.Prefixes, otherPrefix) {
+				return false
+			}
+		}
+		return true
+	case c.Negated && !other.Negated:
+		for _, otherPrefix := range other.Prefixes {
+			if c.Prefixes[0].Overlaps(otherPrefix) {
+				return false
+			}
+		}
+		return true
+	case c.Negated && other.Negated:
+		// other excludes at least everything c excludes
+		return prefixContains(other.Prefixes[0], c.Prefixes[0])
+	default:
+		for _, prefix := range c.Prefixes {
+			if isDefaultRoute(prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func prefixContains(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+func prefixesContain(prefixes []netip.Prefix, inner netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefixContains(prefix, inner) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps returns whether there may be a packet matched by both rules, ignoring actions.
+// The check is conservative: true is returned when overlap cannot be ruled out.
+func (r *Rule) Overlaps(other *Rule) bool {
+	rules, err := r.SplitFamilies()
+	if err != nil {
+		return true
+	}
+
+	otherRules, err := other.SplitFamilies()
+	if err != nil {
+		return true
+	}
+
+	for i := range rules {
+		for j := range otherRules {
+			if rules[i].Proto() == otherRules[j].Proto() && rules[i].overlapsSingle(&otherRules[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overlapsSingle compares two single family rules
+func (r *Rule) overlapsSingle(other *Rule) bool {
+	if r.Direction != other.Direction {
+		return false
+	}
+
+	if r.ProtocolName() != "all" && other.ProtocolName() != "all" && r.ProtocolName() != other.ProtocolName() {
+		return false
+	}
+
+	if !r.Source.overlaps(other.Source) || !r.Destination.overlaps(other.Destination) {
+		return false
+	}
+
+	if !r.Ports.overlaps(other.Ports) || !r.sourcePorts().overlaps(other.sourcePorts()) {
+		return false
+	}
+
+	for _, match := range [][2]string{
+		{r.SourceInterface, other.SourceInterface},
+		{r.DestinationInterface, other.DestinationInterface},
+	} {
+		if match[0] != "" && match[1] != "" && match[0] != match[1] {
+			return false
+		}
+	}
+
+	states := r.flagValues(statePrefix)
+	otherStates := flagSet(other.flagValues(statePrefix))
+	if len(states) > 0 && len(otherStates) > 0 {
+		common := false
+		for _, state := range states {
+			common = common || otherStates[state]
+		}
+		if !common {
+			return false
+		}
+	}
+
+	icmpType, ok := r.icmpType()
+	otherICMPType, otherOk := other.icmpType()
+	if ok && otherOk && !icmpTypesOverlap(icmpType, otherICMPType) {
+		return false
+	}
+	return true
+}
+
+// icmpTypesOverlap compares '<type>[/<code>]' values, type without code matching all codes
+func icmpTypesOverlap(a, b string) bool {
+	if a == "any" || b == "any" {
+		return true
+	}
+
+	typeA, codeA, hasCodeA := strings.Cut(a, "/")
+	typeB, codeB, hasCodeB := strings.Cut(b, "/")
+	if typeA != typeB {
+		return false
+	}
+	return !hasCodeA || !hasCodeB || codeA == codeB
+}
+
+// overlaps returns whether c and other have any addresses in common, both being of single family
+func (c CIDR) overlaps(other CIDR) bool {
+	if c.IsAny() || other.IsAny() || !c.IsResolved() || !other.IsResolved() {
+		return true
+	}
+
+	switch {
+	case !c.Negated && !other.Negated:
+		for _, prefix := range c.Prefixes {
+			for _, otherPrefix := range other.Prefixes {
+				if prefix.Overlaps(otherPrefix) {
+					return true
+				}
+			}
+		}
+		return false
+	case c.Negated && !other.Negated:
+		return !prefixesWithin(other.Prefixes, c.Prefixes[0])
+	case !c.Negated && other.Negated:
+		return !prefixesWithin(c.Prefixes, other.Prefixes[0])
+	default:
+		// Complements of two networks always share addresses, unless they cover the whole address space
+		return true
+	}
+}
+
+func prefixesWithin(prefixes []netip.Prefix, outer netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if !prefixContains(outer, prefix) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package rule
+
+import "sort"
+
+// PriorityOrder returns indexes of rules in installation order: ascending priority, keeping declaration order for equal priorities
+func PriorityOrder(rules []Rule) (order []int) {
+	order = make([]int, len(rules))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return rules[order[i]].Priority < rules[order[j]].Priority
+	})
+	return
+}
+
+// SortByPriority returns rules sorted in installation order, see PriorityOrder
+func SortByPriority(rules []Rule) (sorted []Rule) {
+	for _, idx := range PriorityOrder(rules) {
+		sorted = append(sorted, rules[idx])
+	}
+	return
+}
@@ -0,0 +1,26 @@
+package rule_test
+
+import (
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+func TestSortByPriority(t *testing.T) {
+	rules := []rule.Rule{
+		{ID: "a", Protocol: "tcp", Action: "allow", Priority: 10},
+		{ID: "b", Protocol: "tcp", Action: "allow"},
+		{ID: "c", Protocol: "tcp", Action: "allow", Priority: -5},
+		{ID: "d", Protocol: "tcp", Action: "allow", Priority: 10},
+		{ID: "e", Protocol: "tcp", Action: "allow"},
+	}
+
+	var ids string
+	for _, r := range rule.SortByPriority(rules) {
+		ids += r.ID
+	}
+
+	if ids != "cbead" {
+		t.Errorf("unexpected rule order '%s'", ids)
+	}
+}
@@ -0,0 +1,261 @@
+package rule
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+var validPacketStates = map[string]bool{
+	"new":         true,
+	"established": true,
+	"related":     true,
+	"invalid":     true,
+}
+
+// Packet describes a synthetic packet evaluated against rules, see Rule.Matches
+type Packet struct {
+	Protocol        string     `json:"protocol"`
+	Source          netip.Addr `json:"source"`
+	Destination     netip.Addr `json:"destination"`
+	DestinationPort uint16     `json:"destination_port"`
+	// ICMP or ICMPv6 type as '<name>', '<type>' or '<type>/<code>'
+	ICMPType  string `json:"icmp_type"`
+	SourceMAC string `json:"source_mac"`
+	// Conntrack state, defaults to new
+	State string `json:"state"`
+	// Amount of connections already tracked from the source network, compared against connection limits
+	Connections uint32 `json:"connections"`
+
+	SourcePort   uint16 `json:"source_port"`
+	InInterface  string `json:"in_interface"`
+	OutInterface string `json:"out_interface"`
+	// Flags set on tcp packet ('syn', 'ack', ...) and kinds of tcp options present
+	TCPFlags   []string `json:"tcp_flags"`
+	TCPOptions []uint8  `json:"tcp_options"`
+}
+
+// Family returns address family of the packet
+func (p *Packet) Family() Protocol {
+	if p.Source.Is6() && !p.Source.Is4In6() {
+		return ProtocolIPv6
+	}
+	return ProtocolIPv4
+}
+
+// Validate normalizes the packet description in place
+func (p *Packet) Validate() (err error) {
+	if !p.Source.IsValid() || !p.Destination.IsValid() {
+		err = fmt.Errorf("packet source and destination addresses are required")
+		return
+	}
+	p.Source, p.Destination = p.Source.Unmap(), p.Destination.Unmap()
+
+	if p.Source.Is4() != p.Destination.Is4() {
+		err = fmt.Errorf("packet source and destination address families do not match")
+		return
+	}
+
+	if p.Protocol, err = normalizeProtocol(p.Protocol); err != nil {
+		return
+	}
+
+	if p.Protocol == "all" {
+		err = fmt.Errorf("packet protocol must be specific")
+		return
+	}
+
+	if p.Protocol != "icmpv6" {
+		p.Protocol = strings.TrimSuffix(p.Protocol, "v6")
+	}
+
+	v6 := p.Family() == ProtocolIPv6
+	if (p.Protocol == "icmp" && v6) || (p.Protocol == "icmpv6" && !v6) {
+		err = fmt.Errorf("protocol %s does not match packet address family", p.Protocol)
+		return
+	}
+
+	if p.ICMPType != "" {
+		prefix := icmpTypePrefix
+		if p.Protocol == "icmpv6" {
+			prefix = icmpv6TypePrefix
+		} else if p.Protocol != "icmp" {
+			err = fmt.Errorf("icmp type is supported only with icmp and icmpv6 packets")
+			return
+		}
+
+		icmpType, ok := resolveICMPType(prefix + strings.ToLower(p.ICMPType))
+		if !ok || icmpType == "any" {
+			err = fmt.Errorf("unsupported icmp type '%s'", p.ICMPType)
+			return
+		}
+		p.ICMPType = icmpType
+	}
+
+	if p.SourceMAC != "" {
+		if p.SourceMAC, err = normalizeMAC(p.SourceMAC); err != nil {
+			return
+		}
+
+		if strings.HasPrefix(p.SourceMAC, "!") {
+			err = fmt.Errorf("packet source mac address cannot be negated")
+			return
+		}
+	}
+
+	if p.State, err = normalizeValue("state", p.State, "new", validPacketStates); err != nil {
+		return
+	}
+
+	if (len(p.TCPFlags) > 0 || len(p.TCPOptions) > 0) && p.Protocol != "tcp" {
+		err = fmt.Errorf("tcp flags and options are supported only with tcp packets")
+		return
+	}
+
+	for i, flag := range p.TCPFlags {
+		p.TCPFlags[i] = strings.ToLower(flag)
+		if !validTCPFlags[tcpFlagPrefix+p.TCPFlags[i]] || p.TCPFlags[i] == "all" || p.TCPFlags[i] == "none" {
+			err = fmt.Errorf("unsupported tcp flag '%s'", flag)
+			return
+		}
+	}
+	return
+}
+
+// Matches returns whether the packet would be matched by rulespecs generated from this rule. Rule needs to be
+// resolved and packet validated. Rate limits are assumed to not be exceeded.
+func (r *Rule) Matches(p *Packet) (matches bool, err error) {
+	var rules []Rule
+	if rules, err = r.SplitFamilies(); err != nil {
+		return
+	}
+
+	for i := range rules {
+		fr := &rules[i]
+		if fr.Proto() != p.Family() {
+			continue
+		}
+
+		if !fr.Source.IsResolved() || !fr.Destination.IsResolved() {
+			err = fmt.Errorf("rule contains unresolved hostnames, resolve it first")
+			return
+		}
+
+		if fr.matchesSingle(p) {
+			matches = true
+			return
+		}
+	}
+	return
+}
+
+// matchesSingle matches packet against a single family rule
+func (r *Rule) matchesSingle(p *Packet) bool {
+	if !r.Source.contains(p.Source) || !r.Destination.contains(p.Destination) {
+		return false
+	}
+
+	protocol := r.ProtocolName()
+	if r.Protocol == "icmpv6" {
+		protocol = r.Protocol
+	}
+	if protocol != "all" && protocol != p.Protocol {
+		return false
+	}
+
+	if !r.Ports.contains(p.DestinationPort) || !r.sourcePorts().contains(p.SourcePort) {
+		return false
+	}
+
+	if (r.SourceInterface != "" && r.SourceInterface != p.InInterface) ||
+		(r.DestinationInterface != "" && r.DestinationInterface != p.OutInterface) {
+		return false
+	}
+
+	if !r.tcpFlagsMatch(p.TCPFlags) {
+		return false
+	}
+
+	if kind, ok := r.tcpOption(); ok && !tcpOptionPresent(kind, p.TCPOptions) {
+		return false
+	}
+
+	if icmpType, ok := r.icmpType(); ok && !icmpTypeMatches(icmpType, p.ICMPType) {
+		return false
+	}
+
+	if r.SourceMAC != "" {
+		mac := strings.TrimPrefix(r.SourceMAC, "!")
+		if (mac == p.SourceMAC) == strings.HasPrefix(r.SourceMAC, "!") {
+			return false
+		}
+	}
+
+	if states := r.flagValues(statePrefix); len(states) > 0 && !flagSet(states)[p.State] {
+		return false
+	}
+
+	// Packet being evaluated opens a new connection on top of already tracked ones
+	if r.ConnLimit != nil && p.Connections+1 <= r.ConnLimit.Above {
+		return false
+	}
+	return true
+}
+
+// tcpFlagsMatch returns whether flags set on packet satisfy rule tcp flags
+func (r *Rule) tcpFlagsMatch(packetFlags []string) bool {
+	mask, comp := r.tcpFlags()
+	set, required := flagSet(packetFlags), flagSet(comp)
+	for _, flag := range mask {
+		if set[flag] != required[flag] {
+			return false
+		}
+	}
+	return true
+}
+
+func tcpOptionPresent(kind string, options []uint8) bool {
+	for _, option := range options {
+		if strconv.Itoa(int(option)) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// icmpTypeMatches compares rule '<type>[/<code>]' value against packet one
+func icmpTypeMatches(ruleType, packetType string) bool {
+	if ruleType == "any" {
+		return true
+	}
+
+	if packetType == "" {
+		return false
+	}
+
+	typ, _, hasCode := strings.Cut(ruleType, "/")
+	if hasCode {
+		if !strings.Contains(packetType, "/") {
+			packetType += "/0"
+		}
+		return ruleType == packetType
+	}
+
+	packetTyp, _, _ := strings.Cut(packetType, "/")
+	return typ == packetTyp
+}
+
+// contains returns whether address is matched by c, being resolved and of single family
+func (c CIDR) contains(addr netip.Addr) bool {
+	if c.IsAny() {
+		return true
+	}
+
+	for _, prefix := range c.Prefixes {
+		if prefix.Contains(addr) {
+			return !c.Negated
+		}
+	}
+	return c.Negated
+}
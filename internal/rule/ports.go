@@ -0,0 +1,333 @@
+package rule
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// iptables multiport match accepts up to 15 ports, ranges taking up two of them
+const multiportSlots = 15
+
+var (
+	//go:embed services
+	servicesTable []byte
+	services      map[string]map[string]uint16
+	servicesOnce  sync.Once
+	// Protocols having services in the embedded services table
+	serviceProtocols = map[string]bool{
+		"tcp": true,
+		"udp": true,
+	}
+
+	portRangePattern = regexp.MustCompile(`^(\d+)\s*[-:]\s*(\d+)$`)
+)
+
+// PortRange is an inclusive destination port range. Service holds the service name range was given as
+type PortRange struct {
+	Start   uint16
+	End     uint16
+	Service string
+}
+
+// Ports is a list of destination port ranges, matching when any of them matches. Empty list matches all ports
+type Ports []PortRange
+
+// ParsePorts parses port numbers ('22'), ranges ('1024-4096' or '1024:4096') and service names ('ssh').
+// Service names are resolved by Rule.Validate, as ports depend on the protocol. Only tcp and udp have service names.
+func ParsePorts(values ...string) (ports Ports, err error) {
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+
+		var port PortRange
+		if m := portRangePattern.FindStringSubmatch(value); m != nil {
+			if port.Start, err = parsePort(m[1]); err != nil {
+				return
+			}
+			if port.End, err = parsePort(m[2]); err != nil {
+				return
+			}
+		} else if _, perr := strconv.Atoi(value); perr == nil {
+			if port.Start, err = parsePort(value); err != nil {
+				return
+			}
+			port.End = port.Start
+		} else if value != "" {
+			port.Service = value
+		} else {
+			err = fmt.Errorf("empty port")
+			return
+		}
+
+		if port.Start > port.End {
+			err = fmt.Errorf("port range end cannot be smaller than start (start=%d, end=%d)", port.Start, port.End)
+			return
+		}
+		ports = append(ports, port)
+	}
+	return
+}
+
+// MustParsePorts is like ParsePorts, but panics on error
+func MustParsePorts(values ...string) Ports {
+	ports, err := ParsePorts(values...)
+	if err != nil {
+		panic(err)
+	}
+	return ports
+}
+
+func parsePort(value string) (port uint16, err error) {
+	var n uint64
+	if n, err = strconv.ParseUint(value, 10, 16); err != nil || n == 0 {
+		err = fmt.Errorf("invalid port '%s'", value)
+		return
+	}
+	port = uint16(n)
+	return
+}
+
+// lookupService resolves service name from the embedded services table
+func lookupService(name, protocol string) (port uint16, ok bool) {
+	servicesOnce.Do(func() {
+		services = parseServices(servicesTable)
+	})
+	port, ok = services[protocol][name]
+	return
+}
+
+// parseServices parses /etc/services formatted table into protocol -> name (or alias) -> port map
+func parseServices(table []byte) (parsed map[string]map[string]uint16) {
+	parsed = map[string]map[string]uint16{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(table))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		portValue, protocol, ok := strings.Cut(fields[1], "/")
+		if !ok {
+			continue
+		}
+
+		port, err := parsePort(portValue)
+		if err != nil {
+			continue
+		}
+
+		if parsed[protocol] == nil {
+			parsed[protocol] = map[string]uint16{}
+		}
+
+		for _, name := range append(fields[:1:1], fields[2:]...) {
+			name = strings.ToLower(name)
+			if _, exists := parsed[protocol][name]; !exists {
+				parsed[protocol][name] = port
+			}
+		}
+	}
+	return
+}
+
+// resolve looks up ports for service names
+func (p Ports) resolve(protocol string) (resolved Ports, err error) {
+	for _, port := range p {
+		if port.Service != "" && port.Start == 0 {
+			if !serviceProtocols[protocol] {
+				err = fmt.Errorf("service names are not supported for protocol %s, use port numbers instead", protocol)
+				return
+			}
+
+			var ok bool
+			if port.Start, ok = lookupService(port.Service, protocol); !ok {
+				err = fmt.Errorf("unknown %s service '%s'", protocol, port.Service)
+				return
+			}
+			port.End = port.Start
+		}
+
+		if port.Start == 0 || port.Start > port.End {
+			err = fmt.Errorf("invalid port range %d-%d", port.Start, port.End)
+			return
+		}
+		resolved = append(resolved, port)
+	}
+	return
+}
+
+// multiportGroups splits ports into groups fitting into a single multiport match, nil group matching all ports
+func (p Ports) multiportGroups() (groups []Ports) {
+	if len(p) == 0 {
+		return []Ports{nil}
+	}
+
+	var group Ports
+	slots := 0
+	for _, port := range p {
+		needed := 1
+		if port.Start != port.End {
+			needed = 2
+		}
+
+		if slots+needed > multiportSlots {
+			groups = append(groups, group)
+			group, slots = nil, 0
+		}
+		group = append(group, port)
+		slots += needed
+	}
+	return append(groups, group)
+}
+
+// portMatchspec renders destination port match for ports fitting into a single multiport match
+func portMatchspec(protocol string, ports Ports) []string {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	// There is no dedicated udplite match, thus using multiport
+	if len(ports) == 1 && protocol != "udplite" {
+		return []string{"--dport", ports[0].String()}
+	}
+
+	var values []string
+	for _, port := range ports {
+		values = append(values, port.String())
+	}
+	return []string{"-m", "multiport", "--dports", strings.Join(values, ",")}
+}
+
+// sourcePortMatchspec renders source port match for a single port range
+func sourcePortMatchspec(protocol string, ports Ports) []string {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	if protocol == "udplite" {
+		return []string{"-m", "multiport", "--sports", ports[0].String()}
+	}
+	return []string{"--sport", ports[0].String()}
+}
+
+// String returns port range in iptables format
+func (r PortRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(int(r.Start))
+	}
+	return fmt.Sprintf("%d:%d", r.Start, r.End)
+}
+
+func (r PortRange) contains(other PortRange) bool {
+	return r.Start <= other.Start && other.End <= r.End
+}
+
+func (r PortRange) overlaps(other PortRange) bool {
+	return r.Start <= other.End && other.Start <= r.End
+}
+
+// contains returns whether port is matched
+func (p Ports) contains(port uint16) bool {
+	return len(p) == 0 || p.covers(Ports{{Start: port, End: port}})
+}
+
+// covers returns whether every port matched by other is matched by p
+func (p Ports) covers(other Ports) bool {
+	if len(p) == 0 {
+		return true
+	}
+
+	if len(other) == 0 {
+		return false
+	}
+
+	for _, otherRange := range other {
+		covered := false
+		for _, portRange := range p {
+			covered = covered || portRange.contains(otherRange)
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps returns whether p and other have any ports in common
+func (p Ports) overlaps(other Ports) bool {
+	if len(p) == 0 || len(other) == 0 {
+		return true
+	}
+
+	for _, portRange := range p {
+		for _, otherRange := range other {
+			if portRange.overlaps(otherRange) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r PortRange) jsonValue() interface{} {
+	if r.Service != "" {
+		return r.Service
+	}
+
+	if r.Start == r.End {
+		return r.Start
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+func (p Ports) MarshalJSON() ([]byte, error) {
+	if len(p) == 1 {
+		return json.Marshal(p[0].jsonValue())
+	}
+
+	values := []interface{}{}
+	for _, port := range p {
+		values = append(values, port.jsonValue())
+	}
+	return json.Marshal(values)
+}
+
+func (p *Ports) UnmarshalJSON(b []byte) (err error) {
+	var raw []json.RawMessage
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		*p = nil
+		return
+	} else if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		err = json.Unmarshal(b, &raw)
+	} else {
+		raw = []json.RawMessage{b}
+	}
+	if err != nil {
+		return
+	}
+
+	var values []string
+	for _, value := range raw {
+		var s string
+		if err = json.Unmarshal(value, &s); err != nil {
+			var n json.Number
+			if json.Unmarshal(value, &n) != nil {
+				err = fmt.Errorf("port must be a number or a string, got %s", value)
+				return
+			}
+			s, err = n.String(), nil
+		}
+		values = append(values, s)
+	}
+
+	*p, err = ParsePorts(values...)
+	return
+}
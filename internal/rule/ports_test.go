@@ -0,0 +1,151 @@
+package rule_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+func TestPortsJSON(t *testing.T) {
+	cases := map[string]string{
+		`22`:                       `22`,
+		`"22"`:                     `22`,
+		`"1024-4096"`:              `"1024-4096"`,
+		`"1024:4096"`:              `"1024-4096"`,
+		`"SSH"`:                    `"ssh"`,
+		`[80, 443, "8000-8100"]`:   `[80,443,"8000-8100"]`,
+		`["https", "http-alt", 1]`: `["https","http-alt",1]`,
+	}
+
+	for input, expected := range cases {
+		var ports rule.Ports
+		if err := json.Unmarshal([]byte(input), &ports); err != nil {
+			t.Errorf("failed to unmarshal '%s': %s", input, err)
+			continue
+		}
+
+		output, err := json.Marshal(ports)
+		if err != nil {
+			t.Errorf("failed to marshal '%s': %s", input, err)
+			continue
+		}
+
+		if string(output) != expected {
+			t.Errorf("expected '%s' to marshal as '%s', got '%s'", input, expected, output)
+		}
+	}
+
+	for _, input := range []string{`0`, `65536`, `"4096-1024"`, `""`, `true`, `[[22]]`} {
+		var ports rule.Ports
+		if err := json.Unmarshal([]byte(input), &ports); err == nil {
+			t.Errorf("expected '%s' to be invalid", input)
+		}
+	}
+}
+
+func TestRulePorts(t *testing.T) {
+	var many []string
+	for port := 1; port <= 16; port++ {
+		many = append(many, fmt.Sprint(port))
+	}
+
+	v4 := rule.MustParseCIDR("0.0.0.0/0")
+	cases := []struct {
+		rule     rule.Rule
+		expected []string
+	}{
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "tcp", Ports: rule.MustParsePorts("ssh"), Action: "allow"},
+			expected: []string{"--dport 22 "},
+		},
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "tcp", Ports: rule.MustParsePorts("1024-4096"), Action: "allow"},
+			expected: []string{"--dport 1024:4096 "},
+		},
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "tcp", Ports: rule.MustParsePorts("80", "https", "8000-8100"), Action: "allow"},
+			expected: []string{"-m multiport --dports 80,443,8000:8100 "},
+		},
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "udp", Ports: rule.MustParsePorts("domain"), Action: "allow"},
+			expected: []string{"--dport 53 "},
+		},
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "udplite", Ports: rule.MustParsePorts("5000"), Action: "allow"},
+			expected: []string{"-m multiport --dports 5000 "},
+		},
+		{
+			rule: rule.Rule{Source: v4, Protocol: "tcp", Ports: rule.MustParsePorts(many...), Action: "allow"},
+			expected: []string{
+				"-m multiport --dports 1,2,3,4,5,6,7,8,9,10,11,12,13,14,15 ",
+				"--dport 16 ",
+			},
+		},
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "tcp", Port: 22, Action: "allow"},
+			expected: []string{"--dport 22 "},
+		},
+		{
+			rule:     rule.Rule{Source: v4, Protocol: "tcp", StartPort: 1024, EndPort: 4096, Action: "allow"},
+			expected: []string{"--dport 1024:4096 "},
+		},
+	}
+
+	for _, c := range cases {
+		specs, err := c.rule.ToRulespecs("testchain")
+		if err != nil {
+			t.Errorf("failed to create rules for %v: %s", c.rule.Ports, err)
+			continue
+		}
+
+		if len(specs) != len(c.expected) {
+			t.Errorf("expected %d rulespecs, got %d", len(c.expected), len(specs))
+			continue
+		}
+
+		for i, spec := range specs {
+			if s := strings.Join(spec, " "); !strings.Contains(s, c.expected[i]) {
+				t.Errorf("expected '%s' in '%s'", c.expected[i], s)
+			}
+		}
+	}
+}
+
+func TestRulePortsInvalid(t *testing.T) {
+	rules := []rule.Rule{
+		{Protocol: "tcp", Ports: rule.MustParsePorts("no-such-service"), Action: "allow"},
+		{Protocol: "udp", Ports: rule.MustParsePorts("ssh"), Action: "allow"},
+		{Protocol: "sctp", Ports: rule.MustParsePorts("amqp"), Action: "allow"},
+		{Protocol: "udplite", Ports: rule.MustParsePorts("domain"), Action: "allow"},
+		{Protocol: "tcp", Ports: rule.MustParsePorts("22"), StartPort: 22, Action: "allow"},
+		{Protocol: "tcp", StartPort: 4096, EndPort: 1024, Action: "allow"},
+		{Protocol: "tcp", EndPort: 1024, Action: "allow"},
+		{Protocol: "gre", Ports: rule.MustParsePorts("22"), Action: "allow"},
+		{Protocol: "tcp", SourcePort: 22, SourceStartPort: 22, Action: "allow"},
+		{Protocol: "tcp", SourceStartPort: 4096, SourceEndPort: 1024, Action: "allow"},
+		{Protocol: "tcp", SourceEndPort: 1024, Action: "allow"},
+		{Protocol: "gre", SourcePort: 22, Action: "allow"},
+	}
+
+	for _, r := range rules {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected rule with ports %v (source %d-%d) to be invalid", r.Ports, r.SourceStartPort, r.SourceEndPort)
+		}
+	}
+
+	var r rule.Rule
+	if err := json.Unmarshal([]byte(`{"protocol":"tcp","action":"allow","start":22}`), &r); err != nil {
+		t.Fatalf("failed to unmarshal legacy rule: %s", err)
+	}
+
+	if err := r.Validate(); err != nil {
+		t.Errorf("expected validation to be idempotent: %s", err)
+	}
+
+	if output, _ := json.Marshal(r.Ports); string(output) != `22` {
+		t.Errorf("expected legacy port to be folded into ports, got '%s'", output)
+	}
+}
@@ -1,8 +1,79 @@
 package rule
 
+import "fmt"
+
 type Protocol byte
 
 const (
 	ProtocolIPv4 Protocol = iota
 	ProtocolIPv6
 )
+
+// Families returns address families this rule applies to. Rules using legacy '*v6' protocol names are IPv6 only,
+// other rules cover families present in both source and destination, where any address and negated networks
+// cover both families.
+func (r *Rule) Families() (families []Protocol) {
+	if r.IsV6() {
+		return []Protocol{ProtocolIPv6}
+	}
+
+	if r.ipv4Only {
+		return []Protocol{ProtocolIPv4}
+	}
+
+	// ICMP types differ between families, thus such rules are IPv4 only
+	_, hasICMPType := r.icmpType()
+	icmpTypeV4Only := hasICMPType && r.Protocol == "icmp"
+
+	for _, family := range []Protocol{ProtocolIPv4, ProtocolIPv6} {
+		if family == ProtocolIPv6 && icmpTypeV4Only {
+			continue
+		}
+
+		if r.Source.coversFamily(family) && r.Destination.coversFamily(family) {
+			families = append(families, family)
+		}
+	}
+	return
+}
+
+// SplitFamilies returns a single family rule for every address family this rule applies to
+func (r *Rule) SplitFamilies() (rules []Rule, err error) {
+	if err = r.Validate(); err != nil {
+		return
+	}
+
+	for _, family := range r.Families() {
+		familyRule := *r
+		familyRule.Source = r.Source.family(family)
+		if !r.Destination.IsAny() {
+			familyRule.Destination = r.Destination.family(family)
+		}
+		if family == ProtocolIPv6 && !r.IsV6() {
+			familyRule.Protocol = r.Protocol + "v6"
+		} else if family == ProtocolIPv4 {
+			familyRule.ipv4Only = true
+		}
+		rules = append(rules, familyRule)
+	}
+	return
+}
+
+// singleFamily returns this rule in its family specific form, failing when rule covers multiple families
+func (r *Rule) singleFamily() (familyRule *Rule, err error) {
+	var rules []Rule
+	if rules, err = r.SplitFamilies(); err != nil {
+		return
+	}
+
+	if len(rules) != 1 {
+		err = fmt.Errorf("rule covers %d address families, split it first", len(rules))
+		return
+	}
+	familyRule = &rules[0]
+
+	if !familyRule.Source.IsResolved() || !familyRule.Destination.IsResolved() {
+		err = fmt.Errorf("rule contains unresolved hostnames, resolve it first")
+	}
+	return
+}
@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+
+	"go.uber.org/multierr"
+)
+
+// Resolver looks up addresses of a hostname. network is one of "ip", "ip4" or "ip6". Satisfied by *net.Resolver
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// StaticResolver resolves hostnames using a fixed map, useful for testing and pinned hosts
+type StaticResolver map[string][]netip.Addr
+
+func (s StaticResolver) LookupNetIP(ctx context.Context, network, host string) (addrs []netip.Addr, err error) {
+	for _, addr := range s[host] {
+		if (network == "ip4" && !addr.Is4()) || (network == "ip6" && addr.Is4()) {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return
+}
+
+func (c CIDR) resolve(ctx context.Context, resolver Resolver, network string) (resolved CIDR, err error) {
+	resolved = CIDR{
+		Prefixes: append([]netip.Prefix{}, c.Prefixes...),
+		Negated:  c.Negated,
+	}
+
+	for _, hostname := range c.Hostnames {
+		var addrs []netip.Addr
+		if addrs, err = resolver.LookupNetIP(ctx, network, hostname); err != nil {
+			err = fmt.Errorf("failed to resolve '%s': %w", hostname, err)
+			return
+		}
+
+		if len(addrs) == 0 {
+			err = fmt.Errorf("failed to resolve '%s': no addresses", hostname)
+			return
+		}
+
+		// Keep resolved addresses in stable order, so that changes can be detected by comparing
+		sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+		for _, addr := range addrs {
+			addr = addr.Unmap()
+			resolved.Prefixes = append(resolved.Prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	resolved.Prefixes = dedupPrefixes(resolved.Prefixes)
+	return
+}
+
+// Resolve returns copy of the rule with hostnames in source and destination replaced with their addresses
+func (r *Rule) Resolve(ctx context.Context, resolver Resolver) (resolved Rule, err error) {
+	resolved = *r
+	if err = resolved.foldLegacySource(); err != nil {
+		return
+	}
+
+	network := "ip"
+	if r.IsV6() {
+		network = "ip6"
+	}
+
+	if resolved.Source, err = resolved.Source.resolve(ctx, resolver, network); err != nil {
+		return
+	}
+
+	if resolved.Destination, err = r.Destination.resolve(ctx, resolver, network); err != nil {
+		return
+	}
+
+	err = resolved.Validate()
+	return
+}
+
+// ResolveRules resolves hostnames in all rules. When resolution fails, rules fail closed: allow rules are omitted and
+// block rules match any address instead of unresolved hostnames. Resolution errors are returned along with the rules.
+func ResolveRules(ctx context.Context, resolver Resolver, rules []Rule) (resolved []Rule, err error) {
+	for idx, r := range rules {
+		// Action decides how the rule fails, thus it needs to be normalized first. Invalid rules are returned as is,
+		// to be rejected when applied.
+		if verr := r.Validate(); verr != nil {
+			resolved = append(resolved, rules[idx])
+			continue
+		}
+
+		if r.Source.IsResolved() && r.Destination.IsResolved() {
+			resolved = append(resolved, r)
+			continue
+		}
+
+		resolvedRule, rerr := r.Resolve(ctx, resolver)
+		if rerr == nil {
+			resolved = append(resolved, resolvedRule)
+			continue
+		}
+
+		err = multierr.Append(err, fmt.Errorf("rule %d: %w", idx, rerr))
+		if r.Action == "block" {
+			resolved = append(resolved, r.failClosed())
+		}
+	}
+	return
+}
+
+// failClosed returns copy of the rule where source and destination containing hostnames match any address
+func (r *Rule) failClosed() (closed Rule) {
+	closed = *r
+	if !r.Source.IsResolved() {
+		closed.Source = CIDR{}
+	}
+	if !r.Destination.IsResolved() {
+		closed.Destination = CIDR{}
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package rule_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+func TestResolveRules(t *testing.T) {
+	resolver := rule.StaticResolver{
+		"peer.example.com": {
+			netip.MustParseAddr("2001:db8::10"),
+			netip.MustParseAddr("192.0.2.10"),
+		},
+	}
+
+	rules := []rule.Rule{
+		{
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("peer.example.com"),
+			StartPort: 5432,
+			Action:    "allow",
+		},
+		{
+			Protocol: "tcpv6",
+			// Legacy field name
+			CIDR:      rule.MustParseCIDR("peer.example.com"),
+			StartPort: 6432,
+			Action:    "allow",
+		},
+		{
+			Protocol: "tcp",
+			Source:   rule.MustParseCIDR("missing.example.com"),
+			Action:   "allow",
+		},
+		{
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("missing.example.com"),
+			StartPort: 25,
+			Action:    "block",
+		},
+	}
+
+	if _, err := rules[0].ToRulespecs("testchain"); err == nil {
+		t.Error("expected unresolved rule not to produce rulespecs")
+	}
+
+	resolved, err := rule.ResolveRules(context.Background(), resolver, rules)
+	if err == nil {
+		t.Error("expected resolution of missing hostname to fail")
+	}
+
+	if len(resolved) != 3 {
+		t.Fatalf("expected unresolvable allow rule to be omitted, got %d rules", len(resolved))
+	}
+
+	if cidr := resolved[0].Source.String(); cidr != "192.0.2.10/32,2001:db8::10/128" {
+		t.Errorf("unexpected resolved cidr '%s'", cidr)
+	}
+
+	if cidr := resolved[1].Source.String(); cidr != "2001:db8::10/128" {
+		t.Errorf("unexpected resolved ipv6 only cidr '%s'", cidr)
+	}
+
+	if !resolved[2].Source.IsAny() || resolved[2].Action != "block" {
+		t.Errorf("expected unresolvable block rule to block any address, got '%s'", resolved[2].Source.String())
+	}
+
+	familyRules, err := resolved[0].SplitFamilies()
+	if err != nil {
+		t.Fatalf("failed to split rule: %s", err)
+	}
+
+	rulespec, err := familyRules[1].ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	if joined := strings.Join(rulespec, " "); !strings.HasPrefix(joined, "-s 2001:db8::10/128 -p tcp --dport 5432") {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+}
+
+func TestResolveRulesFailsClosedBeforeNormalization(t *testing.T) {
+	// Action of a rule built in code is normalized by Validate only
+	rules := []rule.Rule{
+		{Protocol: "TCP", Source: rule.MustParseCIDR("missing.example.com"), StartPort: 25, Action: "Block"},
+	}
+
+	resolved, err := rule.ResolveRules(context.Background(), rule.StaticResolver{}, rules)
+	if err == nil {
+		t.Error("expected resolution of missing hostname to fail")
+	}
+
+	if len(resolved) != 1 || !resolved[0].Source.IsAny() || resolved[0].Action != "block" {
+		t.Fatalf("expected unresolvable block rule to block any address, got %v", resolved)
+	}
+}
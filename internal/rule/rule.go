@@ -3,19 +3,41 @@ package rule
 import (
 	"encoding/json"
 	"fmt"
-	"net"
 	"strconv"
 	"strings"
 )
 
 var (
+	// Protocol names without '-v6' suffix, legacy '*v6' names are handled by normalizeProtocol
 	supportedProtocols = map[string]bool{
-		"tcp":    true,
-		"udp":    true,
-		"icmp":   true,
-		"tcpv6":  true,
-		"udpv6":  true,
-		"icmpv6": true,
+		"all":     true,
+		"tcp":     true,
+		"udp":     true,
+		"icmp":    true,
+		"sctp":    true,
+		"udplite": true,
+		"gre":     true,
+		"esp":     true,
+		"ah":      true,
+	}
+	// Well-known protocol numbers normalized into names, iptables treats protocol 0 as all protocols
+	protocolNumbers = map[string]string{
+		"0":   "all",
+		"1":   "icmp",
+		"6":   "tcp",
+		"17":  "udp",
+		"47":  "gre",
+		"50":  "esp",
+		"51":  "ah",
+		"58":  "icmpv6",
+		"132": "sctp",
+		"136": "udplite",
+	}
+	portProtocols = map[string]bool{
+		"tcp":     true,
+		"udp":     true,
+		"sctp":    true,
+		"udplite": true,
 	}
 	supportedActions = map[string]bool{
 		"allow": true,
@@ -28,28 +50,56 @@ var (
 )
 
 type Rule struct {
+	// Optional identifier and description, embedded into generated rule comments. Description must not contain
+	// double quotes
+	ID      string `json:"id"`
+	Comment string `json:"comment"`
+	// Rules with lower priority are installed first, equal priorities keep declaration order
+	Priority int `json:"priority"`
+
 	Protocol  string `json:"protocol"`
-	CIDR      string `json:"cidr"` // TODO: type
 	Action    string `json:"action"`
 	Direction string `json:"direction"`
 
+	Source      CIDR `json:"source"`
+	Destination CIDR `json:"destination"`
+
+	// Deprecated: legacy name of Source, folded into Source by Validate
+	CIDR CIDR `json:"cidr"`
+
+	// Destination ports, only for port-bearing protocols (see HasPorts)
+	Ports Ports `json:"ports"`
+
+	// Legacy port fields, folded into Ports by Validate
 	StartPort uint16 `json:"start"`
 	EndPort   uint16 `json:"end"`
 	Port      uint16 `json:"-"`
 
-	// Only TCP and UDP
+	// Source port or range, only for port-bearing protocols. SourcePort is folded into the range by Validate
 	SourceEndPort   uint16 `json:"source_end"`
 	SourcePort      uint16 `json:"source_port"`
 	SourceStartPort uint16 `json:"source_start"`
 
-	// TODO:
-	// --tcp-flags (SYN, ACK etc.) also prefix with !
-	// --tcp-option option:<value> also prefix with !
-	// --icmp-type (echo-reply, echo-request etc.) also prefix with !
-	// --mac-source (mac) also prefix with !
-	Flags                []string `json:"flags"`
-	SourceInterface      string   `json:"source_interface"`
-	DestinationInterface string   `json:"destination_interface"`
+	// Conntrack states ('state:<state>', any of them matching), icmp type ('icmp:<type>' or 'icmpv6:<type>'),
+	// tcp flags ('tcp:<flag>', all of them set; 'tcp:none' and 'tcp:all' for none or all flags set)
+	// and tcp option ('tcpopt:<kind>', option present)
+	Flags []string `json:"flags"`
+
+	// Interface packet arrives on, only for input rules
+	SourceInterface string `json:"source_interface"`
+	// Interface packet leaves through, only for output rules
+	DestinationInterface string `json:"destination_interface"`
+
+	// Source MAC address, prefix with ! to negate. Only for input rules
+	SourceMAC string `json:"source_mac"`
+
+	RateLimit *RateLimit `json:"rate_limit"`
+	ConnLimit *ConnLimit `json:"conn_limit"`
+
+	Log *Log `json:"log"`
+
+	// Set on IPv4 rules returned by SplitFamilies, as negated networks would cover IPv6 as well
+	ipv4Only bool
 }
 
 func normalizeValue(what, v, def string, validValues map[string]bool) (normalized string, err error) {
@@ -63,6 +113,26 @@ func normalizeValue(what, v, def string, validValues map[string]bool) (normalize
 	return
 }
 
+// normalizeProtocol accepts protocol names (optionally with legacy '-v6' suffix) and protocol numbers
+func normalizeProtocol(v string) (normalized string, err error) {
+	normalized = strings.ToLower(strings.TrimSpace(v))
+	if name, ok := protocolNumbers[normalized]; ok {
+		return name, nil
+	}
+
+	base := strings.TrimSuffix(normalized, "v6")
+	if supportedProtocols[base] {
+		return
+	}
+
+	if n, perr := strconv.ParseUint(base, 10, 8); perr == nil && strconv.FormatUint(n, 10) == base {
+		return
+	}
+
+	err = fmt.Errorf("unsupported protocol: '%s'", normalized)
+	return
+}
+
 func (r *Rule) UnmarshalJSON(b []byte) (err error) {
 	type ruleT Rule
 	if err = json.Unmarshal(b, (*ruleT)(r)); err != nil {
@@ -74,7 +144,7 @@ func (r *Rule) UnmarshalJSON(b []byte) (err error) {
 }
 
 func (r *Rule) Validate() (err error) {
-	if r.Protocol, err = normalizeValue("protocol", r.Protocol, "", supportedProtocols); err != nil {
+	if r.Protocol, err = normalizeProtocol(r.Protocol); err != nil {
 		return
 	}
 
@@ -86,10 +156,23 @@ func (r *Rule) Validate() (err error) {
 		return
 	}
 
+	if err = validateID(r.ID); err != nil {
+		return
+	}
+
+	if err = validateComment(r.Comment); err != nil {
+		return
+	}
+
+	if err = r.foldLegacySource(); err != nil {
+		return
+	}
+
 	// Deduplicate and validate flags
 	collectedFlagsMap := map[string]bool{}
 	newFlags := []string{}
 	for _, flag := range r.Flags {
+		flag = strings.ToLower(flag)
 		if _, ok := collectedFlagsMap[flag]; !ok {
 			collectedFlagsMap[flag] = true
 			newFlags = append(newFlags, flag)
@@ -101,34 +184,172 @@ func (r *Rule) Validate() (err error) {
 		return
 	}
 
+	if err = r.validateInterfaces(); err != nil {
+		return
+	}
+
+	if r.SourceMAC != "" {
+		if r.Direction == "output" {
+			err = fmt.Errorf("source mac address cannot be matched in output rules")
+			return
+		}
+
+		if r.SourceMAC, err = normalizeMAC(r.SourceMAC); err != nil {
+			return
+		}
+	}
+
 	// Validate IP
-	var cidr *net.IPNet
-	if _, cidr, err = net.ParseCIDR(r.CIDR); err != nil {
+	if err = r.Source.validate(); err != nil {
+		err = fmt.Errorf("invalid source: %w", err)
 		return
 	}
 
-	cidrV6 := cidr.IP.To4() == nil
-	if r.IsV6() != cidrV6 {
+	if err = r.Destination.validate(); err != nil {
+		err = fmt.Errorf("invalid destination: %w", err)
+		return
+	}
+
+	if r.IsV6() && (r.Source.HasV4() || r.Destination.HasV4()) {
 		err = fmt.Errorf("ipv4 in ipv6 (or vice versa) rule")
 		return
 	}
 
-	if r.ProtocolName() == "icmp" {
-		r.StartPort = 0
-		r.EndPort = 0
-		r.Port = 0
+	if _, ok := r.icmpType(); ok && r.Protocol == "icmp" && (r.Source.HasV6() || r.Destination.HasV6()) {
+		err = fmt.Errorf("icmp type cannot be matched in ipv6 (use icmpv6 protocol instead)")
+		return
+	}
+
+	if len(r.Families()) == 0 {
+		err = fmt.Errorf("source and destination address families do not match")
+		return
+	}
+
+	for _, family := range r.Families() {
+		v6 := family == ProtocolIPv6
+		if r.RateLimit != nil {
+			if err = r.RateLimit.Validate(v6); err != nil {
+				return
+			}
+		}
+
+		if r.ConnLimit != nil {
+			if r.Action != "block" {
+				err = fmt.Errorf("connection limit is supported only with block action")
+				return
+			}
+
+			if err = r.ConnLimit.Validate(v6); err != nil {
+				return
+			}
+		}
+	}
+
+	if r.Log != nil {
+		if err = r.Log.Validate(); err != nil {
+			return
+		}
+	}
+
+	if !r.HasPorts() {
+		if len(r.Ports) > 0 || r.Port != 0 || r.StartPort != 0 || r.EndPort != 0 || r.SourcePort != 0 || r.SourceStartPort != 0 || r.SourceEndPort != 0 {
+			err = fmt.Errorf("protocol %s does not support ports", r.Protocol)
+			return
+		}
 	} else {
-		if (r.StartPort == r.EndPort) || (r.StartPort != 0 && r.EndPort == 0) {
-			r.Port = r.StartPort
-		} else if r.StartPort > r.EndPort {
-			err = fmt.Errorf("port range end cannot be smaller than start (start=%d, end=%d)", r.StartPort, r.EndPort)
+		if err = r.foldLegacyPorts(); err != nil {
+			return
+		}
+
+		if err = r.foldSourcePort(); err != nil {
+			return
+		}
+
+		if r.Ports, err = r.Ports.resolve(r.ProtocolName()); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// foldLegacySource moves source given using legacy CIDR field into Source
+func (r *Rule) foldLegacySource() (err error) {
+	if r.CIDR.IsAny() {
+		return
+	}
+
+	if !r.Source.IsAny() {
+		err = fmt.Errorf("source cannot be combined with legacy cidr field")
+		return
+	}
+
+	r.Source, r.CIDR = r.CIDR, CIDR{}
+	return
+}
+
+// foldLegacyPorts moves port given using StartPort/EndPort (or Port) into Ports
+func (r *Rule) foldLegacyPorts() (err error) {
+	if r.Port == 0 && r.StartPort == 0 && r.EndPort == 0 {
+		return
+	}
+
+	if len(r.Ports) > 0 {
+		err = fmt.Errorf("ports cannot be combined with legacy start and end fields")
+		return
+	}
+
+	start, end := r.StartPort, r.EndPort
+	if start == 0 && end == 0 {
+		start = r.Port
+	}
+	if end == 0 {
+		end = start
+	}
+
+	if start == 0 {
+		err = fmt.Errorf("port range start is missing (end=%d)", end)
+		return
+	} else if start > end {
+		err = fmt.Errorf("port range end cannot be smaller than start (start=%d, end=%d)", start, end)
+		return
+	}
+
+	r.Ports = Ports{{Start: start, End: end}}
+	r.Port, r.StartPort, r.EndPort = 0, 0, 0
+	return
+}
+
+// foldSourcePort moves single source port given using SourcePort into SourceStartPort and SourceEndPort
+func (r *Rule) foldSourcePort() (err error) {
+	if r.SourcePort != 0 {
+		if r.SourceStartPort != 0 || r.SourceEndPort != 0 {
+			err = fmt.Errorf("source port cannot be combined with source start and end fields")
 			return
 		}
+		r.SourceStartPort, r.SourceEndPort, r.SourcePort = r.SourcePort, r.SourcePort, 0
 	}
 
+	if r.SourceEndPort == 0 {
+		r.SourceEndPort = r.SourceStartPort
+	}
+
+	if r.SourceStartPort == 0 && r.SourceEndPort != 0 {
+		err = fmt.Errorf("source port range start is missing (end=%d)", r.SourceEndPort)
+	} else if r.SourceStartPort > r.SourceEndPort {
+		err = fmt.Errorf("source port range end cannot be smaller than start (start=%d, end=%d)", r.SourceStartPort, r.SourceEndPort)
+	}
 	return
 }
 
+// sourcePorts returns source port range as Ports, empty list matching all ports
+func (r *Rule) sourcePorts() Ports {
+	if r.SourceStartPort == 0 {
+		return nil
+	}
+	return Ports{{Start: r.SourceStartPort, End: r.SourceEndPort}}
+}
+
 func (r *Rule) IsV6() bool {
 	return strings.HasSuffix(r.Protocol, "v6")
 }
@@ -137,18 +358,72 @@ func (r *Rule) ProtocolName() string {
 	return strings.TrimSuffix(r.Protocol, "v6")
 }
 
+// HasPorts returns whether rule protocol carries port numbers
+func (r *Rule) HasPorts() bool {
+	return portProtocols[r.ProtocolName()]
+}
+
+// Proto returns address family of a single family rule, see Families
 func (r *Rule) Proto() Protocol {
-	if r.IsV6() {
-		return ProtocolIPv6
+	if families := r.Families(); len(families) == 1 {
+		return families[0]
 	}
 	return ProtocolIPv4
 }
 
-func (r *Rule) ToRulespec(chainName string) (s []string, err error) {
-	if err = r.Validate(); err != nil {
-		return
+// expansion holds addresses and ports of a single rulespec, empty address matches any address
+type expansion struct {
+	addresses [2]string
+	ports     Ports
+}
+
+// expansions returns every rulespec this rule expands into: source and destination address combinations,
+// each with ports split into multiport sized groups
+func (r *Rule) expansions() (expanded []expansion) {
+	for _, addresses := range r.addresses() {
+		for _, ports := range r.Ports.multiportGroups() {
+			expanded = append(expanded, expansion{addresses: addresses, ports: ports})
+		}
+	}
+	return
+}
+
+// addresses returns source and destination address pair for every rulespec this rule expands into,
+// empty string matches any address
+func (r *Rule) addresses() (pairs [][2]string) {
+	sources := []string{""}
+	if !r.Source.IsAny() {
+		sources = r.Source.entries()
+	}
+
+	destinations := []string{""}
+	if !r.Destination.IsAny() {
+		destinations = r.Destination.entries()
+	}
+
+	for _, source := range sources {
+		for _, destination := range destinations {
+			pairs = append(pairs, [2]string{source, destination})
+		}
 	}
-	s = []string{"-s", r.CIDR}
+	return
+}
+
+func addressMatchspec(flag, address string) []string {
+	if strings.HasPrefix(address, "!") {
+		return []string{"!", flag, strings.TrimPrefix(address, "!")}
+	} else if address != "" {
+		return []string{flag, address}
+	}
+	return nil
+}
+
+// matchspec returns rulespec part selecting packets. role distinguishes rate limit state between
+// rules generated from the same Rule (e.g. log rule)
+func (r *Rule) matchspec(chainName, role string, e expansion) (s []string) {
+	s = append(s, addressMatchspec("-s", e.addresses[0])...)
+	s = append(s, addressMatchspec("-d", e.addresses[1])...)
+	s = append(s, r.interfaceMatchspec()...)
 
 	if r.Protocol != "icmpv6" {
 		s = append(s, "-p", r.ProtocolName())
@@ -156,12 +431,38 @@ func (r *Rule) ToRulespec(chainName string) (s []string, err error) {
 		s = append(s, "-p", r.Protocol)
 	}
 
-	if r.Port > 0 {
-		s = append(s, "--dport", strconv.Itoa(int(r.Port)))
-	} else if r.StartPort > 0 {
-		s = append(s, "--dport", fmt.Sprintf("%d:%d", r.StartPort, r.EndPort))
+	if icmpType, ok := r.icmpType(); ok {
+		s = append(s, "--"+r.Protocol+"-type", icmpType)
+	}
+
+	s = append(s, sourcePortMatchspec(r.ProtocolName(), r.sourcePorts())...)
+	s = append(s, portMatchspec(r.ProtocolName(), e.ports)...)
+	s = append(s, r.tcpFlagsMatchspec()...)
+
+	if kind, ok := r.tcpOption(); ok {
+		s = append(s, "--tcp-option", kind)
+	}
+
+	if r.SourceMAC != "" {
+		s = append(s, macMatchspec(r.SourceMAC)...)
+	}
+
+	if states := r.flagValues(statePrefix); len(states) > 0 {
+		s = append(s, "-m", "conntrack", "--ctstate", strings.ToUpper(strings.Join(states, ",")))
+	}
+
+	if r.ConnLimit != nil {
+		s = append(s, r.ConnLimit.toMatchspec(r.IsV6())...)
 	}
 
+	if r.RateLimit != nil {
+		name := hashlimitName(append([]string{chainName, role, r.RateLimit.Rate}, s...)...)
+		s = append(s, r.RateLimit.toMatchspec(name, r.IsV6())...)
+	}
+	return
+}
+
+func (r *Rule) targetspec() (s []string, err error) {
 	var target string
 	switch r.Action {
 	case "allow":
@@ -183,7 +484,90 @@ func (r *Rule) ToRulespec(chainName string) (s []string, err error) {
 			s = append(s, "--reject-with", "icmp-port-unreachable")
 		}
 	}
+	return
+}
+
+// ToRulespecs returns rulespecs for this rule, one for every source and destination network combination
+// (and group of ports when there are more than fit into a single multiport match).
+// Rules covering both address families need to be split using SplitFamilies first.
+func (r *Rule) ToRulespecs(chainName string) (specs [][]string, err error) {
+	var fr *Rule
+	if fr, err = r.singleFamily(); err != nil {
+		return
+	}
+
+	var target []string
+	if target, err = fr.targetspec(); err != nil {
+		return
+	}
+
+	for _, e := range fr.expansions() {
+		s := fr.matchspec(chainName, "", e)
+		s = append(s, target...)
+		s = append(s, fr.commentspec(chainName)...)
+		specs = append(specs, s)
+	}
+	return
+}
+
+// ToRulespec returns rulespec for a rule which does not expand into multiple rulespecs
+func (r *Rule) ToRulespec(chainName string) (s []string, err error) {
+	var specs [][]string
+	if specs, err = r.ToRulespecs(chainName); err != nil {
+		return
+	}
+
+	s, err = singleRulespec(specs)
+	return
+}
 
-	s = append(s, "-m", "comment", "--comment", fmt.Sprintf("Autogenerated rule using swdfw from '%s'", chainName))
+// ToLogRulespecs returns rulespecs for logging packets matching this rule, or nil when rule is not logged.
+// Returned rulespecs correspond to ones returned by ToRulespecs and must precede them.
+// blockLog is used for block rules which do not have their own log settings.
+func (r *Rule) ToLogRulespecs(chainName string, index int, blockLog *Log) (specs [][]string, err error) {
+	var fr *Rule
+	if fr, err = r.singleFamily(); err != nil {
+		return
+	}
+
+	log := fr.Log
+	if log == nil && fr.Action == "block" && blockLog != nil {
+		logCopy := *blockLog
+		if err = logCopy.Validate(); err != nil {
+			return
+		}
+		log = &logCopy
+	}
+
+	if log == nil {
+		return
+	}
+
+	for _, e := range fr.expansions() {
+		s := fr.matchspec(chainName, "log", e)
+		s = append(s, log.toTargetspec(chainName, index)...)
+		s = append(s, fr.commentspec(chainName)...)
+		specs = append(specs, s)
+	}
+	return
+}
+
+// ToLogRulespec returns log rulespec for a rule which does not expand into multiple rulespecs, see ToLogRulespecs
+func (r *Rule) ToLogRulespec(chainName string, index int, blockLog *Log) (s []string, err error) {
+	var specs [][]string
+	if specs, err = r.ToLogRulespecs(chainName, index, blockLog); err != nil || specs == nil {
+		return
+	}
+
+	s, err = singleRulespec(specs)
+	return
+}
+
+func singleRulespec(specs [][]string) (s []string, err error) {
+	if len(specs) != 1 {
+		err = fmt.Errorf("rule expands into %d rulespecs", len(specs))
+		return
+	}
+	s = specs[0]
 	return
 }
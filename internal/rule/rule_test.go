@@ -2,61 +2,136 @@ package rule_test
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/ZentriaMC/swdfw/internal/golden"
 	"github.com/ZentriaMC/swdfw/internal/rule"
 )
 
-func TestSimpleRule(t *testing.T) {
-	rules := []rule.Rule{
-		{
-			Protocol: "tcp",
-			CIDR:     "10.123.0.1/24",
-			Port:     22,
-			Action:   "allow",
-		},
-		{
-			Protocol:  "tcp",
-			CIDR:      "0.0.0.0/0",
-			Action:    "allow",
-			StartPort: 1024,
-			EndPort:   4096,
-		},
-		{
-			Protocol: "icmp",
-			CIDR:     "10.124.0.0/24",
-			Action:   "allow",
-		},
-		{
-			Protocol: "icmp",
-			CIDR:     "0.0.0.0/0",
-			Action:   "block",
-		},
-		{
-			Protocol: "tcp",
-			CIDR:     "0.0.0.0/0",
-			Action:   "block",
-		},
+// quoteRulespec joins rulespec, quoting arguments containing whitespace or quotes
+func quoteRulespec(rulespec []string) string {
+	quoted := make([]string, 0, len(rulespec))
+	for _, arg := range rulespec {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func TestRulespecGolden(t *testing.T) {
+	cases := []struct {
+		name string
+		rule rule.Rule
+	}{
+		// Protocols
+		{"tcp", rule.Rule{Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.1/24"), Port: 22, Action: "allow"}},
+		{"udp", rule.Rule{Protocol: "udp", Ports: rule.MustParsePorts("53"), Action: "allow"}},
+		{"sctp", rule.Rule{Protocol: "sctp", Ports: rule.MustParsePorts("3868"), Action: "allow"}},
+		{"udplite", rule.Rule{Protocol: "udplite", Ports: rule.MustParsePorts("5000"), Action: "allow"}},
+		{"icmp", rule.Rule{Protocol: "icmp", Source: rule.MustParseCIDR("10.124.0.0/24"), Action: "allow"}},
+		{"icmpv6", rule.Rule{Protocol: "icmpv6", Source: rule.MustParseCIDR("::/0"), Action: "allow"}},
+		{"gre", rule.Rule{Protocol: "gre", Action: "allow"}},
+		{"esp", rule.Rule{Protocol: "esp", Action: "allow"}},
+		{"ah", rule.Rule{Protocol: "ah", Action: "allow"}},
+		{"all", rule.Rule{Protocol: "all", Source: rule.MustParseCIDR("192.0.2.0/24"), Action: "block"}},
+		{"protocol number", rule.Rule{Protocol: "47", Action: "allow"}},
+		{"unnamed protocol number", rule.Rule{Protocol: "115", Action: "allow"}},
+		{"legacy v6 protocol", rule.Rule{Protocol: "tcpv6", Source: rule.MustParseCIDR("::/0"), StartPort: 1024, EndPort: 4096, Action: "allow"}},
+
+		// Actions and directions
+		{"block tcp", rule.Rule{Protocol: "tcp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"}},
+		{"block icmp", rule.Rule{Protocol: "icmp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block"}},
+		{"block v6", rule.Rule{Protocol: "udp", Source: rule.MustParseCIDR("2001:db8::/32"), Action: "block"}},
+		{"output", rule.Rule{Protocol: "tcp", Direction: "output", Destination: rule.MustParseCIDR("198.51.100.0/24"), Port: 443, Action: "allow"}},
+
+		// Addresses
+		{"dual stack", rule.Rule{Protocol: "tcp", Source: rule.MustParseCIDR("10.123.0.0/24", "2001:db8::/32"), Port: 22, Action: "allow"}},
+		{"any address", rule.Rule{Protocol: "tcp", Port: 22, Action: "allow"}},
+		{"source and destination", rule.Rule{Protocol: "tcp", Source: rule.MustParseCIDR("10.0.0.0/8", "172.16.0.0/12"),
+			Destination: rule.MustParseCIDR("192.0.2.1", "192.0.2.2"), Port: 22, Action: "allow"}},
+
+		// Port shapes
+		{"start port", rule.Rule{Protocol: "tcp", StartPort: 22, Action: "allow"}},
+		{"port range", rule.Rule{Protocol: "tcp", StartPort: 1024, EndPort: 4096, Action: "allow"}},
+		{"port list", rule.Rule{Protocol: "tcp", Ports: rule.MustParsePorts("80", "443"), Action: "allow"}},
+		{"port list with range", rule.Rule{Protocol: "udp", Ports: rule.MustParsePorts("53", "8000-8999"), Action: "allow"}},
+		{"service name", rule.Rule{Protocol: "tcp", Ports: rule.MustParsePorts("ssh", "https"), Action: "allow"}},
+		{"many ports", rule.Rule{Protocol: "tcp", Ports: rule.MustParsePorts(
+			"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16"), Action: "allow"}},
+		{"source port", rule.Rule{Protocol: "udp", SourcePort: 123, Port: 123, Action: "allow"}},
+		{"source port range", rule.Rule{Protocol: "tcp", SourceStartPort: 1024, SourceEndPort: 65535, Port: 80, Action: "allow"}},
+		{"udplite source port", rule.Rule{Protocol: "udplite", SourcePort: 5000, Action: "allow"}},
+
+		// Flags
+		{"tcp flag", rule.Rule{Protocol: "tcp", Port: 80, Action: "block", Flags: []string{"TCP:SYN"}}},
+		{"tcp flags", rule.Rule{Protocol: "tcp", Action: "allow", Flags: []string{"tcp:ack", "tcp:syn"}}},
+		{"no tcp flags", rule.Rule{Protocol: "tcp", Action: "block", Flags: []string{"tcp:none"}}},
+		{"all tcp flags", rule.Rule{Protocol: "tcp", Action: "block", Flags: []string{"tcp:all"}}},
+		{"tcp option", rule.Rule{Protocol: "tcp", Port: 22, Action: "allow", Flags: []string{"TCPOPT:30"}}},
+		{"states", rule.Rule{Protocol: "tcp", Port: 22, Action: "allow", Flags: []string{"state:new", "state:established", "state:related"}}},
+		{"invalid state", rule.Rule{Protocol: "all", Action: "block", Flags: []string{"state:invalid"}}},
+		{"icmp type", rule.Rule{Protocol: "icmp", Action: "allow", Flags: []string{"icmp:echo-request"}}},
+		{"icmp type and code", rule.Rule{Protocol: "icmp", Action: "allow", Flags: []string{"icmp:3/4"}}},
+		{"icmpv6 type", rule.Rule{Protocol: "icmpv6", Action: "allow", Flags: []string{"icmpv6:neighbor-solicitation"}}},
+
+		// Other matches
+		{"source interface", rule.Rule{Protocol: "tcp", Port: 22, Action: "allow", SourceInterface: "eth0"}},
+		{"destination interface", rule.Rule{Protocol: "tcp", Direction: "output", Port: 443, Action: "allow", DestinationInterface: "eth1"}},
+		{"source mac", rule.Rule{Protocol: "udp", Port: 67, Action: "allow", SourceMAC: "02:00:00:00:00:01"}},
+		{"negated source mac", rule.Rule{Protocol: "udp", Source: rule.MustParseCIDR("0.0.0.0/0"), Action: "block", SourceMAC: "!02:00:00:00:00:01"}},
+		{"rate limit", rule.Rule{Protocol: "icmp", Action: "allow", RateLimit: &rule.RateLimit{Rate: "5/s"}}},
+		{"per source rate limit", rule.Rule{Protocol: "tcp", Port: 22, Action: "allow",
+			RateLimit: &rule.RateLimit{Rate: "10/min", Burst: 3, PerSource: true, SourceMask: 24}}},
+		{"connection limit", rule.Rule{Protocol: "tcpv6", Source: rule.MustParseCIDR("::/0"), Action: "block", ConnLimit: &rule.ConnLimit{Above: 50, Mask: 64}}},
+
+		// Identification and logging
+		{"id and comment", rule.Rule{ID: "ssh", Comment: "'quoted' comment", Protocol: "tcp", Port: 22, Action: "allow"}},
+		{"log", rule.Rule{Protocol: "tcp", Port: 22, Action: "block", Log: &rule.Log{Level: "info"}}},
+		{"nflog", rule.Rule{Protocol: "tcp", StartPort: 22, Action: "block", Log: &rule.Log{Target: "NFLOG", Group: 5, Prefix: "ssh "}}},
 	}
 
-	var strRules []string
-	for _, r := range rules {
-		rules, err := r.ToRulespec("testchain")
+	var out strings.Builder
+	for _, tc := range cases {
+		split, err := tc.rule.SplitFamilies()
 		if err != nil {
-			t.Fatalf("failed to create rule: %s", err)
+			t.Fatalf("%s: failed to split rule: %s", tc.name, err)
+		}
+
+		for _, r := range split {
+			specs, err := r.ToRulespecs("testchain")
+			if err != nil {
+				t.Fatalf("%s: failed to create rule: %s", tc.name, err)
+			}
+
+			logSpecs, err := r.ToLogRulespecs("testchain", 1, &rule.Log{})
+			if err != nil {
+				t.Fatalf("%s: failed to create log rule: %s", tc.name, err)
+			}
+
+			family := "ipv4"
+			if r.IsV6() {
+				family = "ipv6"
+			}
+
+			fmt.Fprintf(&out, "# %s (%s)\n", tc.name, family)
+			for _, s := range append(logSpecs, specs...) {
+				fmt.Fprintf(&out, "%s\n", quoteRulespec(s))
+			}
 		}
-		strRules = append(strRules, strings.Join(rules, " "))
 	}
 
-	// TODO: verify generated rules
-	fmt.Printf("%s\n", strings.Join(strRules, "\n"))
+	golden.Assert(t, "rulespecs.txt", out.String())
 }
 
 func TestRulesWithFlags(t *testing.T) {
 	mptcpRule := rule.Rule{
 		Protocol: "tcp",
-		CIDR:     "10.123.0.1/24",
+		Source:   rule.MustParseCIDR("10.123.0.1/24"),
 		Port:     22,
 		Action:   "allow",
 		Flags: []string{
@@ -66,7 +141,7 @@ func TestRulesWithFlags(t *testing.T) {
 
 	invalidMultiTCPOptRule := rule.Rule{
 		Protocol: "tcp",
-		CIDR:     "10.123.0.1/24",
+		Source:   rule.MustParseCIDR("10.123.0.1/24"),
 		Port:     3066,
 		Action:   "block",
 		Flags: []string{
@@ -77,7 +152,7 @@ func TestRulesWithFlags(t *testing.T) {
 
 	tcpFlagsInICMPRule := rule.Rule{
 		Protocol: "icmp",
-		CIDR:     "0.0.0.0/0",
+		Source:   rule.MustParseCIDR("0.0.0.0/0"),
 		Port:     4200,
 		Action:   "block",
 		Flags: []string{
@@ -99,4 +174,464 @@ func TestRulesWithFlags(t *testing.T) {
 	if err == nil {
 		t.Error("expected icmp rule containing tcp flags to be invalid")
 	}
+
+	noneWithSYNRule := rule.Rule{
+		Protocol: "tcp",
+		Action:   "block",
+		Flags:    []string{"tcp:none", "tcp:syn"},
+	}
+
+	if err = noneWithSYNRule.Validate(); err == nil {
+		t.Error("expected rule combining tcp:none with other tcp flags to be invalid")
+	}
+
+	rulespec, err := mptcpRule.ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	expected := "-p tcp --dport 22 --tcp-option 30 -j RETURN"
+	if joined := strings.Join(rulespec, " "); !strings.Contains(joined, expected) {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+}
+
+func TestRuleInterfaces(t *testing.T) {
+	invalidRules := map[string]rule.Rule{
+		"destination interface in input": {Protocol: "tcp", Action: "allow", DestinationInterface: "eth0"},
+		"source interface in output":     {Protocol: "tcp", Action: "allow", Direction: "output", SourceInterface: "eth0"},
+		"interface wildcard":             {Protocol: "tcp", Action: "allow", SourceInterface: "eth+"},
+		"too long interface name":        {Protocol: "tcp", Action: "allow", SourceInterface: "interface-name-16"},
+	}
+
+	for name, r := range invalidRules {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected rule with %s to be invalid", name)
+		}
+	}
+}
+
+func TestRuleLogging(t *testing.T) {
+	loggedRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("0.0.0.0/0"),
+		StartPort: 22,
+		Action:    "block",
+		Log: &rule.Log{
+			Target: "NFLOG",
+			Group:  5,
+		},
+	}
+
+	logspec, err := loggedRule.ToLogRulespec("testchain", 3, nil)
+	if err != nil {
+		t.Fatalf("failed to create log rule: %s", err)
+	}
+
+	expected := "-s 0.0.0.0/0 -p tcp --dport 22 -m limit --limit 5/minute --limit-burst 5 -j NFLOG --nflog-group 5 --nflog-prefix testchain:3 "
+	if joined := strings.Join(logspec, " "); !strings.HasPrefix(joined, expected) {
+		t.Errorf("unexpected log rulespec: '%s'", joined)
+	}
+
+	unloggedRule := rule.Rule{
+		Protocol: "tcp",
+		Source:   rule.MustParseCIDR("0.0.0.0/0"),
+		Action:   "allow",
+	}
+
+	logspec, err = unloggedRule.ToLogRulespec("testchain", 0, &rule.Log{})
+	if err != nil {
+		t.Fatalf("failed to create log rule: %s", err)
+	}
+	if logspec != nil {
+		t.Error("expected allow rule not to be logged with block log settings")
+	}
+
+	blockRule := rule.Rule{
+		Protocol: "tcp",
+		Source:   rule.MustParseCIDR("0.0.0.0/0"),
+		Action:   "block",
+	}
+
+	logspec, err = blockRule.ToLogRulespec("a-very-long-chain-name-24", 120, &rule.Log{Limit: "1/s"})
+	if err != nil {
+		t.Fatalf("failed to create log rule: %s", err)
+	}
+
+	expected = "-j LOG --log-prefix a-very-long-chain-name-2:120  --log-level warning"
+	if joined := strings.Join(logspec, " "); !strings.Contains(joined, expected) || !strings.Contains(joined, "--limit 1/second") {
+		t.Errorf("unexpected log rulespec: '%s'", joined)
+	}
+
+	invalidPrefixRule := rule.Rule{
+		Protocol: "tcp",
+		Source:   rule.MustParseCIDR("0.0.0.0/0"),
+		Action:   "block",
+		Log: &rule.Log{
+			Prefix: "this prefix is way too long for LOG",
+		},
+	}
+
+	if err = invalidPrefixRule.Validate(); err == nil {
+		t.Error("expected rule with too long log prefix to be invalid")
+	}
+}
+
+func TestRuleLimits(t *testing.T) {
+	sshRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("0.0.0.0/0"),
+		StartPort: 22,
+		Action:    "allow",
+		RateLimit: &rule.RateLimit{
+			Rate:      "10/min",
+			PerSource: true,
+		},
+	}
+
+	rulespec, err := sshRule.ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	expected := "-m hashlimit --hashlimit-upto 10/minute --hashlimit-burst 5 --hashlimit-mode srcip --hashlimit-srcmask 32 --hashlimit-name swdfw"
+	if joined := strings.Join(rulespec, " "); !strings.Contains(joined, expected) {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	connRule := rule.Rule{
+		Protocol: "tcpv6",
+		Source:   rule.MustParseCIDR("::/0"),
+		Action:   "block",
+		ConnLimit: &rule.ConnLimit{
+			Above: 50,
+			Mask:  64,
+		},
+	}
+
+	rulespec, err = connRule.ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	expected = "-m connlimit --connlimit-above 50 --connlimit-mask 64 --connlimit-saddr"
+	if joined := strings.Join(rulespec, " "); !strings.Contains(joined, expected) {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	invalidRules := map[string]rule.Rule{
+		"invalid rate unit": {
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
+			Action:    "allow",
+			RateLimit: &rule.RateLimit{Rate: "10/fortnight"},
+		},
+		"source mask without per source": {
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
+			Action:    "allow",
+			RateLimit: &rule.RateLimit{Rate: "10/s", SourceMask: 24},
+		},
+		"ipv4 connlimit mask out of range": {
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
+			Action:    "block",
+			ConnLimit: &rule.ConnLimit{Above: 50, Mask: 64},
+		},
+		"connlimit on allow rule": {
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
+			Action:    "allow",
+			ConnLimit: &rule.ConnLimit{Above: 50},
+		},
+		"zero connlimit": {
+			Protocol:  "tcp",
+			Source:    rule.MustParseCIDR("0.0.0.0/0"),
+			Action:    "block",
+			ConnLimit: &rule.ConnLimit{},
+		},
+	}
+
+	for name, r := range invalidRules {
+		if err = r.Validate(); err == nil {
+			t.Errorf("expected rule with %s to be invalid", name)
+		}
+	}
+}
+
+func TestRuleStates(t *testing.T) {
+	newConnRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("0.0.0.0/0"),
+		StartPort: 22,
+		Action:    "allow",
+		Flags: []string{
+			"STATE:NEW",
+			"state:related",
+			"state:new",
+		},
+	}
+
+	rulespec, err := newConnRule.ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	expected := "--dport 22 -m conntrack --ctstate NEW,RELATED -j RETURN"
+	if joined := strings.Join(rulespec, " "); !strings.Contains(joined, expected) {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+}
+
+func TestRuleICMPTypes(t *testing.T) {
+	icmpRules := map[string]rule.Rule{
+		"--icmp-type 8": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"ICMP:echo-request"},
+		},
+		"--icmp-type 3/4": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"icmp:3/4"},
+		},
+		"--icmp-type 11": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"icmp:time-exceeded"},
+		},
+		"--icmpv6-type 135": {
+			Protocol: "icmpv6",
+			Source:   rule.MustParseCIDR("::/0"),
+			Action:   "allow",
+			Flags:    []string{"icmpv6:neighbor-solicitation"},
+		},
+	}
+
+	for expected, r := range icmpRules {
+		rulespec, err := r.ToRulespec("testchain")
+		if err != nil {
+			t.Fatalf("failed to create rule: %s", err)
+		}
+
+		if joined := strings.Join(rulespec, " "); !strings.Contains(joined, expected) {
+			t.Errorf("expected rulespec to contain '%s', got '%s'", expected, joined)
+		}
+	}
+
+	invalidRules := map[string]rule.Rule{
+		"unknown icmp type": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"icmp:bogus"},
+		},
+		"icmp code out of range": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"icmp:3/256"},
+		},
+		"icmpv6 type in icmp rule": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"icmpv6:packet-too-big"},
+		},
+		"multiple icmp types": {
+			Protocol: "icmp",
+			Source:   rule.MustParseCIDR("0.0.0.0/0"),
+			Action:   "allow",
+			Flags:    []string{"icmp:echo-request", "icmp:echo-reply"},
+		},
+	}
+
+	for name, r := range invalidRules {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected rule with %s to be invalid", name)
+		}
+	}
+
+	for _, r := range rule.ICMPv6NeighborDiscoveryRules() {
+		if err := r.Validate(); err != nil {
+			t.Errorf("expected neighbor discovery rule to be valid: %s", err)
+		}
+	}
+}
+
+func TestRuleSourceMAC(t *testing.T) {
+	macRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("0.0.0.0/0"),
+		Action:    "allow",
+		SourceMAC: "! 52-54-00-AB-CD-EF",
+	}
+
+	rulespec, err := macRule.ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	if macRule.SourceMAC != "!52:54:00:ab:cd:ef" {
+		t.Errorf("unexpected normalized mac address '%s'", macRule.SourceMAC)
+	}
+
+	expected := "-m mac ! --mac-source 52:54:00:ab:cd:ef"
+	if joined := strings.Join(rulespec, " "); !strings.Contains(joined, expected) {
+		t.Errorf("unexpected rulespec: '%s'", joined)
+	}
+
+	outputMACRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("0.0.0.0/0"),
+		Action:    "allow",
+		Direction: "output",
+		SourceMAC: "52:54:00:ab:cd:ef",
+	}
+
+	if err = outputMACRule.Validate(); err == nil {
+		t.Error("expected output rule with source mac to be invalid")
+	}
+
+	invalidMACRule := rule.Rule{
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("0.0.0.0/0"),
+		Action:    "allow",
+		SourceMAC: "00:00:5e:00:53:00:00:01",
+	}
+
+	if err = invalidMACRule.Validate(); err == nil {
+		t.Error("expected rule with EUI-64 source mac to be invalid")
+	}
+}
+
+func TestRuleProtocols(t *testing.T) {
+	cases := map[string]rule.Rule{
+		"-s 10.0.0.0/8 -p all -j RETURN": {
+			Protocol: "all",
+			Source:   rule.MustParseCIDR("10.0.0.0/8"),
+			Action:   "allow",
+		},
+		"-s 10.0.0.0/8 -p sctp --dport 3868 -j RETURN": {
+			Protocol:  "SCTP",
+			Source:    rule.MustParseCIDR("10.0.0.0/8"),
+			StartPort: 3868,
+			Action:    "allow",
+		},
+		"-s 10.0.0.0/8 -p udplite -m multiport --dports 5000:5010 -j RETURN": {
+			Protocol:  "udplite",
+			Source:    rule.MustParseCIDR("10.0.0.0/8"),
+			StartPort: 5000,
+			EndPort:   5010,
+			Action:    "allow",
+		},
+		"-s 2001:db8::/32 -p esp -j RETURN": {
+			Protocol: "espv6",
+			Source:   rule.MustParseCIDR("2001:db8::/32"),
+			Action:   "allow",
+		},
+		"-s 10.0.0.0/8 -p gre -j RETURN": {
+			Protocol: "47",
+			Source:   rule.MustParseCIDR("10.0.0.0/8"),
+			Action:   "allow",
+		},
+		"-s 10.0.0.0/8 -p 253 -j REJECT": {
+			Protocol: "253",
+			Source:   rule.MustParseCIDR("10.0.0.0/8"),
+			Action:   "block",
+		},
+	}
+
+	for expected, r := range cases {
+		rulespec, err := r.ToRulespec("testchain")
+		if err != nil {
+			t.Errorf("failed to create rule: %s", err)
+			continue
+		}
+
+		if joined := strings.Join(rulespec, " "); !strings.HasPrefix(joined, expected) {
+			t.Errorf("expected rulespec to start with '%s', got '%s'", expected, joined)
+		}
+	}
+
+	invalidRules := map[string]rule.Rule{
+		"unknown protocol name": {
+			Protocol: "quic",
+			Action:   "allow",
+		},
+		"protocol number out of range": {
+			Protocol: "256",
+			Action:   "allow",
+		},
+		"ports on portless protocol": {
+			Protocol:  "gre",
+			StartPort: 22,
+			Action:    "allow",
+		},
+		"ports on all protocols": {
+			Protocol:  "all",
+			StartPort: 22,
+			Action:    "allow",
+		},
+	}
+
+	for name, r := range invalidRules {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected rule with %s to be invalid", name)
+		}
+	}
+}
+
+func TestRuleComments(t *testing.T) {
+	commentedRule := rule.Rule{
+		ID:        "ssh-office",
+		Comment:   "SSH from office\nrequested in OPS-42",
+		Protocol:  "tcp",
+		Source:    rule.MustParseCIDR("192.0.2.0/24"),
+		StartPort: 22,
+		Action:    "allow",
+	}
+
+	rulespec, err := commentedRule.ToRulespec("testchain")
+	if err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	comment := rulespec[len(rulespec)-1]
+	if expected := "Autogenerated rule using swdfw from 'testchain' id 'ssh-office': SSH from office requested in OPS-42"; comment != expected {
+		t.Errorf("unexpected comment '%s'", comment)
+	}
+
+	chainName, id, userComment, ok := rule.ParseComment(comment)
+	if !ok || chainName != "testchain" || id != "ssh-office" || userComment != "SSH from office requested in OPS-42" {
+		t.Errorf("failed to parse comment '%s' (chain=%s, id=%s, comment=%s)", comment, chainName, id, userComment)
+	}
+
+	commentedRule.Comment = strings.Repeat("ä", 200)
+	if rulespec, err = commentedRule.ToRulespec("testchain"); err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	comment = rulespec[len(rulespec)-1]
+	if len(comment) > 255 || !utf8.ValidString(comment) {
+		t.Errorf("expected comment to be truncated into 255 bytes of valid utf-8, got %d bytes", len(comment))
+	}
+
+	if _, _, _, ok = rule.ParseComment(rule.FormatComment("testchain", "", "")); !ok {
+		t.Error("expected comment without id to be parsed")
+	}
+
+	commentedRule.ID = "invalid id"
+	if err = commentedRule.Validate(); err == nil {
+		t.Error("expected rule with invalid id to be invalid")
+	}
+
+	commentedRule.ID = "ssh-office"
+	commentedRule.Comment = `"quoted" comment`
+	if err = commentedRule.Validate(); err == nil {
+		t.Error("expected rule with double quoted comment to be invalid")
+	}
 }
@@ -8,7 +8,8 @@ import (
 
 func (r *Rule) validateFlags() (err error) {
 	proto := r.Protocol
-	validFlags := []map[string]bool{validICMPTypes, validStates}
+	validFlags := []map[string]bool{validStates}
+	icmpPrefix := ""
 
 	switch proto {
 	case "tcp", "tcpv6":
@@ -16,14 +17,29 @@ func (r *Rule) validateFlags() (err error) {
 	case "udp", "udpv6":
 		// no-op
 	case "icmp":
-		validFlags = append(validFlags, validICMPTypes)
+		icmpPrefix = icmpTypePrefix
 	case "icmpv6":
-		validFlags = append(validFlags, validICMPV6Types)
+		icmpPrefix = icmpv6TypePrefix
 	}
 
 	hasTCPOpt := false
+	hasICMPType := false
 	for _, flag := range r.Flags {
 		flagLower := strings.ToLower(flag)
+		if icmpPrefix != "" && strings.HasPrefix(flagLower, icmpPrefix) {
+			if _, ok := resolveICMPType(flagLower); !ok {
+				err = fmt.Errorf("unsupported icmp type '%s' for protocol %s", flag, proto)
+				return
+			}
+
+			if hasICMPType {
+				err = errors.New("cannot specify multiple icmp types in a single rule")
+				return
+			}
+			hasICMPType = true
+			continue
+		}
+
 		if !containsFlag(validFlags, flagLower) {
 			err = fmt.Errorf("unsupported flag '%s' for protocol %s", flag, proto)
 			return
@@ -38,6 +54,11 @@ func (r *Rule) validateFlags() (err error) {
 		}
 	}
 
+	if tcpFlags := flagSet(r.flagValues(tcpFlagPrefix)); len(tcpFlags) > 1 && (tcpFlags["none"] || tcpFlags["all"]) {
+		err = errors.New("tcp:none and tcp:all cannot be combined with other tcp flags")
+		return
+	}
+
 	return
 }
 
@@ -50,3 +71,13 @@ func containsFlag(flagsList []map[string]bool, flag string) (ok bool) {
 
 	return
 }
+
+// flagValues returns values of flags with given prefix, prefix stripped
+func (r *Rule) flagValues(prefix string) (values []string) {
+	for _, flag := range r.Flags {
+		if strings.HasPrefix(flag, prefix) {
+			values = append(values, strings.TrimPrefix(flag, prefix))
+		}
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package simulate
+
+import (
+	"fmt"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+)
+
+type Verdict string
+
+const (
+	// Packet matched an allow rule, chain returns (RETURN)
+	VerdictAllow Verdict = "allow"
+	// Packet matched a block rule, packet is rejected (REJECT)
+	VerdictBlock Verdict = "block"
+	// No rule matched, packet continues in chain's jumpTo target
+	VerdictJump Verdict = "jump"
+	// No rule matched and chain has no jumpTo target, packet returns to the calling chain
+	VerdictFallthrough Verdict = "fallthrough"
+	// Packet was dropped by conntrack prelude (DROP)
+	VerdictDrop Verdict = "drop"
+)
+
+type evaluateConfig struct {
+	conntrackPrelude  bool
+	neighborDiscovery bool
+}
+
+type EvaluateOpt func(cfg *evaluateConfig)
+
+// WithConntrackPrelude evaluates packets as chain configured with chain.WithConntrackPrelude
+func WithConntrackPrelude(enable bool) EvaluateOpt {
+	return func(cfg *evaluateConfig) {
+		cfg.conntrackPrelude = enable
+	}
+}
+
+// WithICMPv6NeighborDiscovery evaluates packets as chain configured with chain.WithICMPv6NeighborDiscovery
+func WithICMPv6NeighborDiscovery(enable bool) EvaluateOpt {
+	return func(cfg *evaluateConfig) {
+		cfg.neighborDiscovery = enable
+	}
+}
+
+// Result describes how a chain configured with ConfigureChain handles a packet
+type Result struct {
+	Verdict Verdict
+	// iptables target the packet ends up in, empty for fallthrough
+	Target string
+	// Index of the first matching rule in the evaluated slice, -1 when none of them matched
+	Index int
+	Rule  *rule.Rule
+	// Rules installed by chain options which matched the packet, e.g. "conntrack prelude"
+	Automatic string
+}
+
+func (r Result) String() string {
+	if r.Automatic != "" {
+		return fmt.Sprintf("%s by %s", r.Verdict, r.Automatic)
+	} else if r.Index < 0 {
+		return fmt.Sprintf("%s (no rule matched)", r.Verdict)
+	}
+	return fmt.Sprintf("%s by rule %d", r.Verdict, r.Index)
+}
+
+// Evaluate returns the verdict of a chain set up with given rules and jumpTo target for given packet.
+// Rules are evaluated in installation order (see rule.PriorityOrder), mirroring rulespecs generated by
+// rule.Rule.ToRulespecs. Rules with hostnames need to be resolved first. Options need to match ChainOpts the
+// chain is configured with, as these install rules preceding the given ones.
+func Evaluate(rules []rule.Rule, jumpTo string, packet rule.Packet, opts ...EvaluateOpt) (result Result, err error) {
+	cfg := &evaluateConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if err = packet.Validate(); err != nil {
+		err = fmt.Errorf("invalid packet: %w", err)
+		return
+	}
+
+	result.Index = -1
+	if cfg.conntrackPrelude {
+		result.Automatic = "conntrack prelude"
+		switch packet.State {
+		case "established", "related":
+			result.Verdict, result.Target = VerdictAllow, "RETURN"
+			return
+		case "invalid":
+			result.Verdict, result.Target = VerdictDrop, "DROP"
+			return
+		}
+		result.Automatic = ""
+	}
+
+	if cfg.neighborDiscovery {
+		for _, r := range rule.ICMPv6NeighborDiscoveryRules() {
+			var matches bool
+			if matches, err = r.Matches(&packet); err != nil {
+				err = fmt.Errorf("failed to evaluate neighbor discovery rule: %w", err)
+				return
+			} else if !matches {
+				continue
+			}
+
+			result.Automatic = "icmpv6 neighbor discovery"
+			err = result.apply(&r)
+			return
+		}
+	}
+
+	for _, idx := range rule.PriorityOrder(rules) {
+		r := rules[idx]
+
+		var matches bool
+		if matches, err = r.Matches(&packet); err != nil {
+			err = fmt.Errorf("failed to evaluate rule %d: %w", idx, err)
+			return
+		}
+
+		if !matches {
+			continue
+		}
+
+		result.Index = idx
+		err = result.apply(&r)
+		return
+	}
+
+	if jumpTo != "" {
+		result.Verdict, result.Target = VerdictJump, jumpTo
+	} else {
+		result.Verdict = VerdictFallthrough
+	}
+	return
+}
+
+// apply sets verdict of a matching rule
+func (r *Result) apply(matched *rule.Rule) (err error) {
+	r.Rule = matched
+	switch matched.Action {
+	case "allow":
+		r.Verdict, r.Target = VerdictAllow, "RETURN"
+	case "block":
+		r.Verdict, r.Target = VerdictBlock, "REJECT"
+	default:
+		err = fmt.Errorf("unhandled action '%s'", matched.Action)
+	}
+	return
+}
@@ -0,0 +1,283 @@
+package simulate_test
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/ZentriaMC/swdfw/internal/rule"
+	"github.com/ZentriaMC/swdfw/internal/simulate"
+)
+
+type corpusScenario struct {
+	Name    string      `json:"name"`
+	JumpTo  string      `json:"jump_to"`
+	Rules   []rule.Rule `json:"rules"`
+	Packets []struct {
+		Packet  rule.Packet      `json:"packet"`
+		Rule    string           `json:"rule"`
+		Verdict simulate.Verdict `json:"verdict"`
+	} `json:"packets"`
+}
+
+func TestEvaluateCorpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/corpus.json")
+	if err != nil {
+		t.Fatalf("failed to read corpus: %s", err)
+	}
+
+	var scenarios []corpusScenario
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		t.Fatalf("failed to parse corpus: %s", err)
+	}
+
+	for _, scenario := range scenarios {
+		for i, expected := range scenario.Packets {
+			result, err := simulate.Evaluate(scenario.Rules, scenario.JumpTo, expected.Packet)
+			if err != nil {
+				t.Errorf("%s/%d: failed to evaluate: %s", scenario.Name, i, err)
+				continue
+			}
+
+			var id string
+			if result.Rule != nil {
+				id = result.Rule.ID
+			}
+
+			if result.Verdict != expected.Verdict || id != expected.Rule {
+				t.Errorf("%s/%d: expected %s by '%s', got %s by '%s'", scenario.Name, i, expected.Verdict, expected.Rule, result.Verdict, id)
+			}
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules := []rule.Rule{
+		{Protocol: "icmpv6", Flags: []string{"icmpv6:neighbor-solicitation"}, Action: "allow"},
+		{Protocol: "icmp", Flags: []string{"icmp:port-unreachable"}, Action: "allow"},
+		{Protocol: "tcp", Flags: []string{"state:established", "state:related"}, Action: "allow"},
+		{Protocol: "tcp", StartPort: 22, ConnLimit: &rule.ConnLimit{Above: 3}, Action: "block"},
+		{Protocol: "tcp", SourceMAC: "!00:11:22:33:44:55", StartPort: 2222, Action: "block"},
+		{Protocol: "tcp", Source: rule.MustParseCIDR("2001:db8::/32"), StartPort: 22, Action: "allow"},
+		{Protocol: "tcp", SourceInterface: "eth1", SourceStartPort: 1024, SourceEndPort: 2048, StartPort: 8080,
+			Flags: []string{"tcp:syn", "tcpopt:30"}, Action: "allow"},
+	}
+
+	mptcpPacket := func(modify func(p *rule.Packet)) (p rule.Packet) {
+		p = rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"),
+			SourcePort: 1500, DestinationPort: 8080, InInterface: "eth1", TCPFlags: []string{"SYN", "ACK"}, TCPOptions: []uint8{2, 30}}
+		if modify != nil {
+			modify(&p)
+		}
+		return
+	}
+
+	cases := []struct {
+		name    string
+		packet  rule.Packet
+		index   int
+		verdict simulate.Verdict
+	}{
+		{
+			name:    "icmpv6 by name",
+			packet:  rule.Packet{Protocol: "icmpv6", Source: netip.MustParseAddr("fe80::1"), Destination: netip.MustParseAddr("fe80::2"), ICMPType: "135"},
+			index:   0,
+			verdict: simulate.VerdictAllow,
+		},
+		{
+			name:    "icmp code mismatch",
+			packet:  rule.Packet{Protocol: "icmp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), ICMPType: "3/1"},
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+		{
+			name:    "icmp code match",
+			packet:  rule.Packet{Protocol: "icmp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), ICMPType: "port-unreachable"},
+			index:   1,
+			verdict: simulate.VerdictAllow,
+		},
+		{
+			name:    "established connection",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("2001:db8::1"), Destination: netip.MustParseAddr("2001:db8::2"), DestinationPort: 80, State: "established"},
+			index:   2,
+			verdict: simulate.VerdictAllow,
+		},
+		{
+			name:    "connection limit not reached",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("2001:db8::1"), Destination: netip.MustParseAddr("2001:db8::2"), DestinationPort: 22, Connections: 2},
+			index:   5,
+			verdict: simulate.VerdictAllow,
+		},
+		{
+			name:    "connection limit exceeded",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("2001:db8::1"), Destination: netip.MustParseAddr("2001:db8::2"), DestinationPort: 22, Connections: 3},
+			index:   3,
+			verdict: simulate.VerdictBlock,
+		},
+		{
+			name:    "allowed mac",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), DestinationPort: 2222, SourceMAC: "00-11-22-33-44-55"},
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+		{
+			name:    "other mac",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), DestinationPort: 2222, SourceMAC: "00:11:22:33:44:66"},
+			index:   4,
+			verdict: simulate.VerdictBlock,
+		},
+		{
+			name:    "mptcp syn",
+			packet:  mptcpPacket(nil),
+			index:   6,
+			verdict: simulate.VerdictAllow,
+		},
+		{
+			name:    "other interface",
+			packet:  mptcpPacket(func(p *rule.Packet) { p.InInterface = "eth0" }),
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+		{
+			name:    "source port out of range",
+			packet:  mptcpPacket(func(p *rule.Packet) { p.SourcePort = 80 }),
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+		{
+			name:    "syn flag missing",
+			packet:  mptcpPacket(func(p *rule.Packet) { p.TCPFlags = []string{"ack"} }),
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+		{
+			name:    "mptcp option missing",
+			packet:  mptcpPacket(func(p *rule.Packet) { p.TCPOptions = []uint8{2} }),
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+		{
+			name:    "ipv4 does not match ipv6 rule",
+			packet:  rule.Packet{Protocol: "6", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), DestinationPort: 22},
+			index:   -1,
+			verdict: simulate.VerdictJump,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := simulate.Evaluate(rules, "SWDFW-DEFAULT", c.packet)
+			if err != nil {
+				t.Fatalf("failed to evaluate: %s", err)
+			}
+
+			if result.Index != c.index || result.Verdict != c.verdict {
+				t.Errorf("expected %s by rule %d, got %s", c.verdict, c.index, result)
+			}
+		})
+	}
+}
+
+func TestEvaluateChainOptions(t *testing.T) {
+	rules := []rule.Rule{
+		{Protocol: "all", Action: "block"},
+	}
+
+	cases := []struct {
+		name    string
+		packet  rule.Packet
+		opts    []simulate.EvaluateOpt
+		verdict simulate.Verdict
+		target  string
+	}{
+		{
+			name:    "established without prelude",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), State: "established"},
+			verdict: simulate.VerdictBlock,
+			target:  "REJECT",
+		},
+		{
+			name:    "established with prelude",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), State: "established"},
+			opts:    []simulate.EvaluateOpt{simulate.WithConntrackPrelude(true)},
+			verdict: simulate.VerdictAllow,
+			target:  "RETURN",
+		},
+		{
+			name:    "invalid with prelude",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), State: "invalid"},
+			opts:    []simulate.EvaluateOpt{simulate.WithConntrackPrelude(true)},
+			verdict: simulate.VerdictDrop,
+			target:  "DROP",
+		},
+		{
+			name:    "new with prelude",
+			packet:  rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2")},
+			opts:    []simulate.EvaluateOpt{simulate.WithConntrackPrelude(true)},
+			verdict: simulate.VerdictBlock,
+			target:  "REJECT",
+		},
+		{
+			name:    "neighbor solicitation without neighbor discovery",
+			packet:  rule.Packet{Protocol: "icmpv6", Source: netip.MustParseAddr("fe80::1"), Destination: netip.MustParseAddr("fe80::2"), ICMPType: "neighbor-solicitation"},
+			verdict: simulate.VerdictBlock,
+			target:  "REJECT",
+		},
+		{
+			name:    "neighbor solicitation with neighbor discovery",
+			packet:  rule.Packet{Protocol: "icmpv6", Source: netip.MustParseAddr("fe80::1"), Destination: netip.MustParseAddr("fe80::2"), ICMPType: "neighbor-solicitation"},
+			opts:    []simulate.EvaluateOpt{simulate.WithICMPv6NeighborDiscovery(true)},
+			verdict: simulate.VerdictAllow,
+			target:  "RETURN",
+		},
+		{
+			name:    "echo request with neighbor discovery",
+			packet:  rule.Packet{Protocol: "icmpv6", Source: netip.MustParseAddr("fe80::1"), Destination: netip.MustParseAddr("fe80::2"), ICMPType: "echo-request"},
+			opts:    []simulate.EvaluateOpt{simulate.WithICMPv6NeighborDiscovery(true)},
+			verdict: simulate.VerdictBlock,
+			target:  "REJECT",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := simulate.Evaluate(rules, "", c.packet, c.opts...)
+			if err != nil {
+				t.Fatalf("failed to evaluate: %s", err)
+			}
+
+			if result.Verdict != c.verdict || result.Target != c.target {
+				t.Errorf("expected %s (%s), got %s (%s)", c.verdict, c.target, result, result.Target)
+			}
+
+			if automatic := len(c.opts) > 0 && result.Verdict != simulate.VerdictBlock; automatic != (result.Automatic != "") || automatic != (result.Index < 0) {
+				t.Errorf("unexpected result '%s' (index %d)", result, result.Index)
+			}
+		})
+	}
+}
+
+func TestEvaluateInvalid(t *testing.T) {
+	packets := []rule.Packet{
+		{Protocol: "tcp"},
+		{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("2001:db8::1")},
+		{Protocol: "icmpv6", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2")},
+		{Protocol: "udp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), ICMPType: "8"},
+		{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), State: "closed"},
+		{Protocol: "udp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), TCPFlags: []string{"syn"}},
+		{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2"), TCPFlags: []string{"all"}},
+	}
+
+	for _, packet := range packets {
+		if _, err := simulate.Evaluate(nil, "", packet); err == nil {
+			t.Errorf("expected packet %+v to be rejected", packet)
+		}
+	}
+
+	unresolved := []rule.Rule{{Protocol: "tcp", Source: rule.MustParseCIDR("example.com"), Action: "allow"}}
+	packet := rule.Packet{Protocol: "tcp", Source: netip.MustParseAddr("192.0.2.1"), Destination: netip.MustParseAddr("192.0.2.2")}
+	if _, err := simulate.Evaluate(unresolved, "", packet); err == nil {
+		t.Errorf("expected unresolved rules to fail evaluation")
+	}
+}